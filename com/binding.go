@@ -4,16 +4,19 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"path"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	auth "github.com/abbot/go-http-auth"
 )
 
+// Headers forwarded to modules
+const (
+	HeaderClientSubject = "X-Client-Subject"
+	HeaderClientVerify  = "X-Client-Verify"
+)
+
 // ReverseProxyAuth - Authentication middleware
 func ReverseProxyAuth(a *auth.BasicAuth) HandlerFunc {
 	return HandlerFunc(func(ctx *Context) {
@@ -21,154 +24,92 @@ func ReverseProxyAuth(a *auth.BasicAuth) HandlerFunc {
 		if user == "" {
 			ctx.ResponseWriter.Header().Add("WWW-Authenticate", "Basic realm="+strconv.Quote(a.Realm))
 			ctx.ResponseWriter.WriteHeader(http.StatusUnauthorized)
+			return
 		}
-		ctx.ResponseWriter.Header().Set("user", user)
+
+		//FORWARDED TO MODULE WITH HEADER RULES ('{{.User}}')
+		ctx.User = user
 		ReverseProxy().Handle(ctx)
 	})
 }
 
+// LoadingRetryAfter - Seconds clients are asked to wait for a loading module
+var LoadingRetryAfter = 10
+
 // ReverseProxyFix - reverse proxy for mod
 func ReverseProxy() HandlerFunc {
 	return HandlerFunc(func(ctx *Context) {
 
-		path := ctx.URL.Path
-
 		routeConfig := ctx.RouteConfig
 		route := ctx.Route
 
+		var state ModuleState
+		var binding ServerConfig
+		if routeConfig != nil {
+			state = routeConfig.State()
+			binding = routeConfig.Binding()
+		}
+
 		//CHECK IF MODULE IS ONLINE
-		if routeConfig != nil && routeConfig.STATE == Online {
+		if routeConfig != nil && state == Online {
 
 			//IF ROOT IS PRESENT REDIRECT TO IT
-			if strings.Contains(routeConfig.TYPES, "bind") && routeConfig.BINDING.ROOT != "" {
-				http.ServeFile(ctx.ResponseWriter, ctx.Request, routeConfig.BINDING.ROOT)
+			if strings.Contains(routeConfig.TYPES, "bind") && binding.ROOT != "" {
+				http.ServeFile(ctx.ResponseWriter, ctx.Request, binding.ROOT)
 
 				//ELSE IF BINDING IS TYPE **REVERSE**
 			} else if strings.Contains(routeConfig.TYPES, "reverse") {
 
-				if route.FROM != route.TO {
-					if route.FROM != "/" {
-						i := strings.Index(path, route.FROM)
-						path = path[i+len(route.FROM):]
-					} else {
-						log.Println(path)
-					}
-
-					if route.TO != "/" && len(route.TO) > 1 && !strings.Contains(path, route.TO) {
-						path = route.TO + path
-					}
-				}
+				//BUILD URL PROXY FROM REWRITTEN PATH, ENCODING AND QUERY KEPT
+				mp := routeConfig.moduleProxy()
+				urlProxy := mp.target(ctx.routeRewriter().RewriteURL(ctx.URL))
 
-				//BUILD URL PROXY
-				urlProxy, err := url.Parse(routeConfig.BINDING.PROTOCOL + "://" + routeConfig.BINDING.ADDRESS + ":" + routeConfig.BINDING.PORT + path)
-				if err != nil {
-					log.Println(err) //TODO ERROR HANDLING
+				//WEBSOCKET AND OTHER PROTOCOL UPGRADES ARE TUNNELED
+				if IsUpgrade(ctx.Request) {
+					proxyUpgrade(ctx, urlProxy)
+					return
 				}
 
-				//TODO ADD CUSTOM HEADERS HERE
-
-				//SETUP REVERSE PROXY DIRECTOR
-				proxy := httputil.NewSingleHostReverseProxy(urlProxy)
-				proxy.Director = func(req *http.Request) {
-
-					req.URL.Scheme = urlProxy.Scheme
-					req.Host = urlProxy.Host
-					req.URL.Host = urlProxy.Host
-					req.URL.Path = urlProxy.Path
-
-					if _, ok := req.Header["User-Agent"]; !ok {
-						req.Header.Set("User-Agent", "")
-					}
+				//STREAMING ROUTES ARE NEVER BUFFERED NOR CUT BY WRITE TIMEOUT
+				if route.STREAMING {
+					disableWriteDeadline(ctx.ResponseWriter)
 				}
-				proxy.ErrorHandler = ErrorHandler
-				proxy.ModifyResponse = Handle404Status
-				proxy.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+
+				mp.serve(ctx, urlProxy, route.STREAMING)
 			}
 		} else {
-			title := ""
-			code := 500
-			message := ""
-			if routeConfig != nil && (routeConfig.STATE == Loading || routeConfig.STATE == Downloaded) {
-				title = "Loading"
-				code += 3
-				message = "Module is loading ..."
-			} else if routeConfig != nil && routeConfig.STATE == Stopped {
-				title = "Stopped"
-				code = 410
-				message = "Module stopped by an administrator"
-			} else if routeConfig == nil || routeConfig.STATE == Error || routeConfig.STATE == Unknown {
-				title = "Error"
-				message = "Error"
-			}
-
-			data := ErrorPage{
-				Title:   title,
-				Code:    code,
-				Message: message,
+			data := ErrorPage{Code: http.StatusInternalServerError, Message: "Error"}
+			if routeConfig != nil && (state == Loading || state == Downloaded) {
+				data = ErrorPage{Title: "Loading", Code: http.StatusServiceUnavailable, Message: "Module is loading ...", RetryAfter: LoadingRetryAfter}
+			} else if routeConfig != nil && state == Stopped {
+				data = ErrorPage{Title: "Stopped", Code: http.StatusServiceUnavailable, Message: "Module stopped by an administrator"}
 			}
-
-			tmpl := template.Must(template.ParseFiles("./resources/html/loading.html"))
-			tmpl.Execute(ctx.ResponseWriter, data)
+			WriteError(ctx.ResponseWriter, ctx.Request, routeConfig, data)
 		}
 	})
 }
 
-// FileBind - File bind handler
-func FileBind(fileName string, r Route) HandlerFunc {
-	return HandlerFunc(func(ctx *Context) {
-		if fileName != "" {
-			http.ServeFile(ctx.ResponseWriter, ctx.Request, fileName)
-		} else {
-			ctx.Text(400, "GO-WOXY Core - Error Bind - "+fileName+" was not found")
-		}
-	})
+// IsEventStream - Response is a Server-Sent Events stream
+func IsEventStream(h http.Header) bool {
+	//PARAMETERS IGNORED WITHOUT PARSING, CHECKED ON EVERY PROXIED RESPONSE
+	mediaType, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream")
 }
 
-// ErrorHandler -
-func ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	title := "Error"
-	//message := "Error"
-
-	data := ErrorPage{
-		Title:   title,
-		Code:    400,
-		Message: err.Error(),
+// disableWriteDeadline - Let long lived response outlast server write timeout
+func disableWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Println("GO-WOXY Core - Error disabling write deadline :", err)
 	}
-
-	tmpl := template.Must(template.ParseFiles("./resources/html/loading.html"))
-	tmpl.Execute(w, data)
 }
 
-func Error404() HandlerFunc {
+// FileBind - File bind handler
+func FileBind(fileName string, r Route) HandlerFunc {
 	return HandlerFunc(func(ctx *Context) {
-		fp := path.Join("resources/html", "404.html")
-		tmpl, err := template.ParseFiles(fp)
-		if err != nil {
-			log.Println("GO-WOXY Core - Error 404 template Not Found")
-			http.Error(ctx.ResponseWriter, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		ctx.ResponseWriter.WriteHeader(404)
-		if err := tmpl.Execute(ctx.ResponseWriter, nil); err != nil {
-			log.Println("GO-WOXY Core - Error executing 404 template")
-			http.Error(ctx.ResponseWriter, err.Error(), http.StatusInternalServerError)
+		if fileName != "" {
+			http.ServeFile(ctx.ResponseWriter, ctx.Request, fileName)
 		} else {
-			log.Println("GO-WOXY Core - 404 Not Found")
+			ctx.Text(400, "GO-WOXY Core - Error Bind - "+fileName+" was not found")
 		}
 	})
 }
-
-// - Throw err when proxied response status is 404
-func Handle404Status(res *http.Response) error {
-	if res.StatusCode == 404 {
-		return errors.New("404 error from the host")
-	}
-	return nil
-}
-
-// ErrorPage - Content description for go-woxy error page
-type ErrorPage struct {
-	Title   string
-	Code    int
-	Message string
-}
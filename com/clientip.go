@@ -0,0 +1,48 @@
+package com
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP - Resolve the real client address of request
+// X-Forwarded-For is only read when the peer is a trusted proxy, walking it
+// from the right and stopping at the first untrusted address
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host := remoteHost(r)
+	ip := net.ParseIP(host)
+	if ip == nil || !ContainsIP(trusted, ip) {
+		return host
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(h, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP := net.ParseIP(hops[i])
+		if hopIP == nil {
+			break
+		}
+		host = hopIP.String()
+		if !ContainsIP(trusted, hopIP) {
+			break
+		}
+	}
+	return host
+}
+
+// remoteHost - Host part of the request remote address
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,42 @@
+package com
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"no header", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"spoofed header from untrusted peer", "203.0.113.7:1234", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"trusted peer", "10.0.0.1:1234", []string{"198.51.100.2"}, "198.51.100.2"},
+		{"trusted peer without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"walk trusted hops from right", "10.0.0.1:1234", []string{"198.51.100.2, 192.168.1.1, 10.0.0.2"}, "198.51.100.2"},
+		{"client spoofed hops left of first untrusted", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.2, 10.0.0.2"}, "198.51.100.2"},
+		{"several headers", "10.0.0.1:1234", []string{"1.2.3.4", "198.51.100.2"}, "198.51.100.2"},
+		{"garbage hop stops walk", "10.0.0.1:1234", []string{"198.51.100.2, garbage"}, "10.0.0.1"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"ipv6 peer", "[2001:db8::1]:1234", []string{"1.2.3.4"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package com
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+type compressOptions struct {
+	LEVEL int
+	TYPES []string
+}
+
+// compressMiddleware - Gzip response compression
+func compressMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	opts := compressOptions{
+		LEVEL: gzip.DefaultCompression,
+		TYPES: []string{"text/", "application/json", "application/javascript", "application/xml", "image/svg+xml"},
+	}
+	if err := DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+
+	//CHECK LEVEL BEFORE SERVING
+	if _, err := gzip.NewWriterLevel(nil, opts.LEVEL); err != nil {
+		return nil, err
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *Context) {
+			if !strings.Contains(ctx.Request.Header.Get("Accept-Encoding"), "gzip") || ctx.Request.Method == http.MethodHead || IsUpgrade(ctx.Request) || (ctx.Route != nil && ctx.Route.STREAMING) {
+				next.Handle(ctx)
+				return
+			}
+
+			gw := &gzipWriter{ResponseWriter: ctx.ResponseWriter, level: opts.LEVEL, types: opts.TYPES}
+			ctx.ResponseWriter = gw
+			next.Handle(ctx)
+			gw.Close()
+		})
+	}, nil
+}
+
+// gzipWriter - ResponseWriter compressing body when content type allows it
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	level       int
+	types       []string
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if h.Get("Content-Encoding") == "" && !IsEventStream(h) && code != http.StatusNoContent && code != http.StatusNotModified && w.compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close - Flush remaining compressed data
+func (w *gzipWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Unwrap - Give access to the underlying ResponseWriter
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipWriter) compressible(contentType string) bool {
+	for _, t := range w.types {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
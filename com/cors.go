@@ -0,0 +1,91 @@
+package com
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type corsOptions struct {
+	CREDENTIALS bool
+	EXPOSE      []string
+	HEADERS     []string
+	MAX_AGE     int
+	METHODS     []string
+	ORIGINS     []string
+}
+
+// corsMiddleware - Cross-Origin Resource Sharing headers and preflight handling
+func corsMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	opts := corsOptions{
+		METHODS: []string{http.MethodGet, http.MethodPost, http.MethodHead},
+		ORIGINS: []string{"*"},
+	}
+	if err := DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+
+	methods := strings.Join(opts.METHODS, ", ")
+	headers := strings.Join(opts.HEADERS, ", ")
+	expose := strings.Join(opts.EXPOSE, ", ")
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *Context) {
+			origin := ctx.Request.Header.Get("Origin")
+			h := ctx.ResponseWriter.Header()
+			h.Add("Vary", "Origin")
+
+			if origin == "" || !opts.allowOrigin(origin) {
+				next.Handle(ctx)
+				return
+			}
+
+			if opts.CREDENTIALS || !opts.wildcard() {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if opts.CREDENTIALS {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			//PREFLIGHT REQUEST
+			if ctx.Request.Method == http.MethodOptions && ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				} else if rh := ctx.Request.Header.Get("Access-Control-Request-Headers"); rh != "" {
+					h.Set("Access-Control-Allow-Headers", rh)
+				}
+				if opts.MAX_AGE > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(opts.MAX_AGE))
+				}
+				ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if expose != "" {
+				h.Set("Access-Control-Expose-Headers", expose)
+			}
+			next.Handle(ctx)
+		})
+	}, nil
+}
+
+func (o *corsOptions) wildcard() bool {
+	for _, origin := range o.ORIGINS {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *corsOptions) allowOrigin(origin string) bool {
+	for _, allowed := range o.ORIGINS {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package com
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed resources/html/*.html
+var embeddedPages embed.FS
+
+// ErrorPage - Content description for go-woxy error page
+type ErrorPage struct {
+	Title      string `json:"title"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// ErrorPages - Error page templates named by status ('404.html'), status class ('5xx.html') or fallback ('error.html')
+type ErrorPages struct {
+	pages map[string]*template.Template
+}
+
+var (
+	defaultPages = mustErrorPages(embeddedPages, "resources/html")
+	globalPages  *ErrorPages
+	globalMux    sync.RWMutex
+)
+
+// LoadErrorPages - Parse error page templates of directory
+func LoadErrorPages(dir string) (*ErrorPages, error) {
+	return parseErrorPages(os.DirFS(dir), ".")
+}
+
+// SetErrorPages - Templates used before embedded ones by every module, nil to reset
+func SetErrorPages(ep *ErrorPages) {
+	globalMux.Lock()
+	defer globalMux.Unlock()
+	globalPages = ep
+}
+
+func mustErrorPages(fsys fs.FS, dir string) *ErrorPages {
+	ep, err := parseErrorPages(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return ep
+}
+
+func parseErrorPages(fsys fs.FS, dir string) (*ErrorPages, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	ep := &ErrorPages{pages: make(map[string]*template.Template, len(files))}
+	for _, f := range files {
+		tmpl, err := template.ParseFS(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		ep.pages[strings.ToLower(strings.TrimSuffix(path.Base(f), ".html"))] = tmpl
+	}
+	return ep, nil
+}
+
+// lookup - Most specific template of status, nil without one
+func (ep *ErrorPages) lookup(code int) *template.Template {
+	if ep == nil {
+		return nil
+	}
+	s := strconv.Itoa(code)
+	for _, name := range []string{s, s[:1] + "xx", "error"} {
+		if tmpl, ok := ep.pages[name]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// errorTemplate - Module, then global, then embedded template of status
+func errorTemplate(rc *RouteConfig, code int) *template.Template {
+	if rc != nil {
+		if tmpl := rc.errorPages().lookup(code); tmpl != nil {
+			return tmpl
+		}
+	}
+	globalMux.RLock()
+	tmpl := globalPages.lookup(code)
+	globalMux.RUnlock()
+	if tmpl != nil {
+		return tmpl
+	}
+	return defaultPages.lookup(code)
+}
+
+// WriteError - Send error page, as JSON to clients preferring it
+func WriteError(w http.ResponseWriter, r *http.Request, rc *RouteConfig, data ErrorPage) {
+	writeErrorPage(w, r, errorTemplate(rc, data.Code), data)
+}
+
+func writeErrorPage(w http.ResponseWriter, r *http.Request, tmpl *template.Template, data ErrorPage) {
+	if data.Title == "" {
+		data.Title = http.StatusText(data.Code)
+	}
+	if data.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(data.RetryAfter))
+	}
+	w.Header().Del("Content-Length")
+
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(data.Code)
+		json.NewEncoder(w).Encode(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(data.Code)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println("GO-WOXY Core - Error executing error page template :", err)
+	}
+}
+
+// acceptRank - Quality given to a media type by its most specific Accept range
+type acceptRank struct {
+	q           float64
+	specificity int
+}
+
+func (ar *acceptRank) offer(q float64, specificity int) {
+	if specificity > ar.specificity || (specificity == ar.specificity && q > ar.q) {
+		ar.q, ar.specificity = q, specificity
+	}
+}
+
+// acceptsJSON - Accept header rates JSON higher than HTML, explicit JSON beating wildcards at equal quality
+func acceptsJSON(r *http.Request) bool {
+	var jsonRank, htmlRank acceptRank
+	for _, h := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(h, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			switch {
+			case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+				jsonRank.offer(q, 3)
+			case mediaType == "application/*":
+				jsonRank.offer(q, 2)
+			case mediaType == "text/html":
+				htmlRank.offer(q, 3)
+			case mediaType == "text/*":
+				htmlRank.offer(q, 2)
+			case mediaType == "*/*":
+				jsonRank.offer(q, 1)
+				htmlRank.offer(q, 1)
+			}
+		}
+	}
+
+	//EQUAL QUALITY : HTML UNLESS JSON IS NAMED MORE PRECISELY
+	if jsonRank.q != htmlRank.q {
+		return jsonRank.q > htmlRank.q
+	}
+	return jsonRank.q > 0 && jsonRank.specificity > htmlRank.specificity
+}
+
+// ErrorHandler - Module proxy error page, keeping intercepted module status
+func ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	var rc *RouteConfig
+	if pr, ok := r.Context().Value(proxyKey{}).(*proxyRequest); ok {
+		rc = pr.ctx.RouteConfig
+	}
+
+	//REQUEST CUT BY MODULE TIMEOUT RATHER THAN BY CLIENT
+	if cause := context.Cause(r.Context()); errors.Is(cause, context.DeadlineExceeded) {
+		err = cause
+	}
+	WriteError(w, r, rc, ErrorPage{Code: errorStatus(err), Message: err.Error()})
+}
+
+// proxyError - Module proxy error page of request context
+func (c *Context) proxyError(err error) {
+	WriteError(c.ResponseWriter, c.Request, c.RouteConfig, ErrorPage{Code: errorStatus(err), Message: err.Error()})
+}
+
+// Error404 - Page of requests matching no route
+func Error404() HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		log.Println("GO-WOXY Core - 404 Not Found")
+		WriteError(ctx.ResponseWriter, ctx.Request, ctx.RouteConfig, ErrorPage{Code: http.StatusNotFound, Message: "Not Found"})
+	})
+}
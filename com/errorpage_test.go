@@ -0,0 +1,110 @@
+package com
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"application/json, */*", true},
+		{"*/*, application/json", true},
+		{"application/json, text/html", false},
+		{"text/html, application/json", false},
+		{"application/json, text/*", true},
+		{"application/*, text/*", false},
+		{"application/*, */*", true},
+		{"text/html;q=0.9, application/json", true},
+		{"application/json;q=0.5, */*", false},
+		{"application/json;q=0.5, text/html;q=0.1, */*", true},
+		{"application/json;q=0, */*", false},
+		{"text/html, */*;q=0.8", false},
+		{"application/json;q=abc", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := acceptsJSON(r); got != tt.want {
+			t.Errorf("acceptsJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// errorPagesDir - Directory of templates answering their file name
+func errorPagesDir(t *testing.T, prefix string, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name+".html"), []byte(prefix+" "+name+" {{.Code}}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestErrorTemplateLookup(t *testing.T) {
+	module, err := LoadErrorPages(errorPagesDir(t, "module", "404"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	global, err := LoadErrorPages(errorPagesDir(t, "global", "404", "5xx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetErrorPages(global)
+	t.Cleanup(func() { SetErrorPages(nil) })
+	rc := &RouteConfig{NAME: "app", pages: module}
+
+	tests := []struct {
+		name string
+		rc   *RouteConfig
+		code int
+		want string
+	}{
+		{"module status page", rc, 404, "module 404 404"},
+		{"global page without module", nil, 404, "global 404 404"},
+		{"global class page", rc, 503, "global 5xx 503"},
+		{"embedded fallback", rc, 401, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), tt.rc, ErrorPage{Code: tt.code})
+			if w.Code != tt.code {
+				t.Errorf("status %d, want %d", w.Code, tt.code)
+			}
+			body := strings.TrimSpace(w.Body.String())
+			if tt.want == "" {
+				if !strings.HasPrefix(body, "<!DOCTYPE html>") || !strings.Contains(body, "401") {
+					t.Errorf("body %q, want embedded page", body)
+				}
+			} else if body != tt.want {
+				t.Errorf("body %q, want %q", body, tt.want)
+			}
+		})
+	}
+
+	//JSON CLIENTS GET THE SAME STATUS WITHOUT TEMPLATE
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json")
+	WriteError(w, r, rc, ErrorPage{Code: 503, RetryAfter: 30})
+	if w.Code != 503 || w.Header().Get("Content-Type") != "application/json" || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("json error : %d %v", w.Code, w.Header())
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"code":503`) || !strings.Contains(body, `"title":"Service Unavailable"`) {
+		t.Errorf("json body %s", body)
+	}
+}
@@ -0,0 +1,97 @@
+package com
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorPolicy - Module response statuses replaced by go-woxy error pages, others being passed through
+// Statuses are codes ('404'), classes ('5xx') or ranges ('500-503'), pass ones winning
+type ErrorPolicy struct {
+	INTERCEPT []string
+	PASS      []string
+}
+
+// UpstreamError - Module response replaced by an error page, keeping its status
+type UpstreamError struct {
+	Module     string
+	StatusCode int
+}
+
+func (e *UpstreamError) Error() string {
+	return "module " + e.Module + " answered " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// Validate - Check status patterns
+func (ep ErrorPolicy) Validate() error {
+	for _, p := range append(append([]string{}, ep.INTERCEPT...), ep.PASS...) {
+		if _, _, err := statusRange(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Intercepts - Status is replaced by an error page
+func (ep ErrorPolicy) Intercepts(code int) bool {
+	return statusIn(ep.INTERCEPT, code) && !statusIn(ep.PASS, code)
+}
+
+func statusIn(patterns []string, code int) bool {
+	for _, p := range patterns {
+		if min, max, err := statusRange(p); err == nil && code >= min && code <= max {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRange - Bounds of status pattern
+func statusRange(p string) (int, int, error) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	if len(p) == 3 && strings.HasSuffix(p, "xx") && p[0] >= '1' && p[0] <= '5' {
+		class := int(p[0]-'0') * 100
+		return class, class + 99, nil
+	}
+	bounds := strings.SplitN(p, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || min < 100 || min > 599 {
+		return 0, 0, errors.New("invalid status " + p)
+	}
+	max := min
+	if len(bounds) == 2 {
+		max, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil || max < min || max > 599 {
+			return 0, 0, errors.New("invalid status range " + p)
+		}
+	}
+	return min, max, nil
+}
+
+// interceptStatus - Error replacing module response when policy intercepts its status
+func interceptStatus(res *http.Response, rc *RouteConfig) error {
+	if rc == nil || !rc.ErrorPolicy().Intercepts(res.StatusCode) {
+		return nil
+	}
+	return &UpstreamError{Module: rc.NAME, StatusCode: res.StatusCode}
+}
+
+// errorStatus - Status of proxy error, module one when intercepted
+func errorStatus(err error) int {
+	var upstream *UpstreamError
+	if errors.As(err, &upstream) {
+		return upstream.StatusCode
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
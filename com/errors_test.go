@@ -0,0 +1,115 @@
+package com
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStatusRange(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		min, max int
+		valid    bool
+	}{
+		{"404", 404, 404, true},
+		{" 5xx ", 500, 599, true},
+		{"4XX", 400, 499, true},
+		{"500-503", 500, 503, true},
+		{"500 - 503", 500, 503, true},
+		{"6xx", 0, 0, false},
+		{"0xx", 0, 0, false},
+		{"99", 0, 0, false},
+		{"600", 0, 0, false},
+		{"503-500", 0, 0, false},
+		{"500-600", 0, 0, false},
+		{"abc", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		min, max, err := statusRange(tt.pattern)
+		if (err == nil) != tt.valid || min != tt.min || max != tt.max {
+			t.Errorf("statusRange(%q) = %d, %d, %v, want %d, %d, valid %v", tt.pattern, min, max, err, tt.min, tt.max, tt.valid)
+		}
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	ep := ErrorPolicy{INTERCEPT: []string{"404", "5xx", "429-431"}, PASS: []string{"501", "503-504"}}
+	if err := ep.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{404, true},
+		{403, false},
+		{500, true},
+		{502, true},
+		{599, true},
+		{429, true},
+		{431, true},
+		{432, false},
+		//PASS WINNING OVER INTERCEPT
+		{501, false},
+		{503, false},
+		{504, false},
+		{200, false},
+	}
+	for _, tt := range tests {
+		if got := ep.Intercepts(tt.code); got != tt.want {
+			t.Errorf("Intercepts(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	if (ErrorPolicy{}).Intercepts(500) {
+		t.Error("empty policy intercepts 500")
+	}
+	if err := (ErrorPolicy{PASS: []string{"5x"}}).Validate(); err == nil {
+		t.Error("invalid pass status accepted")
+	}
+}
+
+func TestInterceptStatusProxy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      ErrorPolicy
+		code        int
+		passed      bool
+		contentType string
+	}{
+		{"module 404 passed through by default", ErrorPolicy{}, http.StatusNotFound, true, "text/plain"},
+		{"intercepted 404 keeps status", ErrorPolicy{INTERCEPT: []string{"4xx"}}, http.StatusNotFound, false, "text/html; charset=utf-8"},
+		{"passed status untouched", ErrorPolicy{INTERCEPT: []string{"4xx"}, PASS: []string{"404"}}, http.StatusNotFound, true, "text/plain"},
+		{"intercepted 500", ErrorPolicy{INTERCEPT: []string{"5xx"}}, http.StatusInternalServerError, false, "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, rc := testRouter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.code)
+				io.WriteString(w, "module page")
+			}), Route{FROM: "/app", TO: "/"})
+			rc.ERRORS = tt.policy
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/missing", nil))
+			if w.Code != tt.code {
+				t.Errorf("status %d, want %d", w.Code, tt.code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("content type %q, want %q", ct, tt.contentType)
+			}
+			if got := w.Body.String() == "module page"; got != tt.passed {
+				t.Errorf("module body passed = %v, want %v", got, tt.passed)
+			}
+			if !tt.passed && !strings.Contains(w.Body.String(), strconv.Itoa(tt.code)) {
+				t.Errorf("error page without status %d", tt.code)
+			}
+		})
+	}
+}
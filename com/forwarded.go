@@ -0,0 +1,102 @@
+package com
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Forwarding headers set on requests to modules
+const (
+	HeaderForwarded       = "Forwarded"
+	HeaderForwardedFor    = "X-Forwarded-For"
+	HeaderForwardedHost   = "X-Forwarded-Host"
+	HeaderForwardedPrefix = "X-Forwarded-Prefix"
+	HeaderForwardedProto  = "X-Forwarded-Proto"
+)
+
+// forwardHeaders - Headers set by go-woxy on requests to modules, overwriting client ones
+// X-Forwarded-For is completed with the peer address by the caller
+func forwardHeaders(req *http.Request, ctx *Context) {
+	//FORWARD VERIFIED CLIENT CERTIFICATE
+	req.Header.Del(HeaderClientSubject)
+	req.Header.Set(HeaderClientVerify, "NONE")
+	if ctx.ClientSubject != "" {
+		req.Header.Set(HeaderClientSubject, ctx.ClientSubject)
+		req.Header.Set(HeaderClientVerify, "SUCCESS")
+	}
+
+	if ctx.RouteConfig != nil && ctx.RouteConfig.PreserveHost() {
+		req.Host = ctx.Request.Host
+	}
+
+	//HEADERS FROM UNTRUSTED PEERS CAN'T BE BELIEVED
+	h := req.Header
+	if !ctx.trustedPeer {
+		for _, name := range []string{HeaderForwarded, HeaderForwardedFor, HeaderForwardedHost, HeaderForwardedPrefix, HeaderForwardedProto} {
+			h.Del(name)
+		}
+	}
+
+	proto := "http"
+	if ctx.Request.TLS != nil {
+		proto = "https"
+	}
+	if h.Get(HeaderForwardedProto) == "" {
+		h.Set(HeaderForwardedProto, proto)
+	}
+	if h.Get(HeaderForwardedHost) == "" {
+		h.Set(HeaderForwardedHost, ctx.Request.Host)
+	}
+	if prefix := ctx.strippedPrefix(); prefix != "" && h.Get(HeaderForwardedPrefix) == "" {
+		h.Set(HeaderForwardedPrefix, prefix)
+	}
+
+	//RFC 7239 ELEMENT APPENDED TO THE ONES OF TRUSTED PROXIES
+	element := "for=" + forwardedNode(remoteHost(ctx.Request)) + ";host=" + forwardedValue(ctx.Request.Host) + ";proto=" + proto
+	if prior := h.Values(HeaderForwarded); len(prior) > 0 {
+		element = strings.Join(prior, ", ") + ", " + element
+	}
+	h.Set(HeaderForwarded, element)
+
+	rewriteRequestHeaders(h, ctx)
+}
+
+// strippedPrefix - Route path removed before proxying, modules needing it to build their urls
+func (ctx *Context) strippedPrefix() string {
+	if ctx.Route == nil {
+		return ""
+	}
+	for _, step := range ctx.routeRewriter().steps {
+		if prefix := strings.TrimSuffix(step.strip, "/"); prefix != "" {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// forwardedNode - RFC 7239 node, IPv6 being bracketed and quoted
+func forwardedNode(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "unknown"
+	}
+	if ip.To4() == nil {
+		return `"[` + ip.String() + `]"`
+	}
+	return ip.String()
+}
+
+// forwardedValue - RFC 7239 value, quoted when not a token
+func forwardedValue(v string) string {
+	for _, c := range v {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+	}
+	return v
+}
+
+func isTokenChar(c rune) bool {
+	return c < 127 && c > 32 && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c)
+}
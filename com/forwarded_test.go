@@ -0,0 +1,108 @@
+package com
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardHeaders(t *testing.T) {
+	seen := make(chan http.Header, 1)
+	r, _ := testRouter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Clone()
+	}), Route{FROM: "/app", TO: "/"})
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.TrustedProxies = trusted
+
+	tests := []struct {
+		name   string
+		remote string
+		in     http.Header
+		want   http.Header
+	}{
+		{
+			name:   "untrusted peer headers replaced",
+			remote: "203.0.113.7:1234",
+			in: http.Header{
+				"X-Forwarded-For":    {"1.2.3.4"},
+				"X-Forwarded-Host":   {"evil.com"},
+				"X-Forwarded-Proto":  {"https"},
+				"X-Forwarded-Prefix": {"/evil"},
+				"Forwarded":          {"for=1.2.3.4;host=evil.com"},
+			},
+			want: http.Header{
+				"X-Forwarded-For":    {"203.0.113.7"},
+				"X-Forwarded-Host":   {"example.com"},
+				"X-Forwarded-Proto":  {"http"},
+				"X-Forwarded-Prefix": {"/app"},
+				"Forwarded":          {"for=203.0.113.7;host=example.com;proto=http"},
+			},
+		},
+		{
+			name:   "trusted peer headers appended",
+			remote: "10.0.0.1:1234",
+			in: http.Header{
+				"X-Forwarded-For":   {"198.51.100.2"},
+				"X-Forwarded-Host":  {"public.example.com"},
+				"X-Forwarded-Proto": {"https"},
+				"Forwarded":         {"for=198.51.100.2;host=public.example.com;proto=https"},
+			},
+			want: http.Header{
+				"X-Forwarded-For":    {"198.51.100.2, 10.0.0.1"},
+				"X-Forwarded-Host":   {"public.example.com"},
+				"X-Forwarded-Proto":  {"https"},
+				"X-Forwarded-Prefix": {"/app"},
+				"Forwarded":          {"for=198.51.100.2;host=public.example.com;proto=https, for=10.0.0.1;host=example.com;proto=http"},
+			},
+		},
+		{
+			name:   "ipv6 peer quoted",
+			remote: "[2001:db8::1]:1234",
+			want: http.Header{
+				"X-Forwarded-For": {"2001:db8::1"},
+				"Forwarded":       {`for="[2001:db8::1]";host=example.com;proto=http`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/app/index", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.in {
+				req.Header[k] = v
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			h := <-seen
+			for k, v := range tt.want {
+				if got := h.Values(k); len(got) != len(v) || got[0] != v[0] {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestForwardedValues(t *testing.T) {
+	tests := []struct {
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{forwardedNode, "192.0.2.1", "192.0.2.1"},
+		{forwardedNode, "2001:db8::1", `"[2001:db8::1]"`},
+		{forwardedNode, "::ffff:192.0.2.1", "192.0.2.1"},
+		{forwardedNode, "@unix", "unknown"},
+		{forwardedValue, "example.com", "example.com"},
+		{forwardedValue, "example.com:8080", `"example.com:8080"`},
+		{forwardedValue, `a"b`, `"a\"b"`},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("%q : got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,14 @@
 module github.com/Wariie/go-woxy/com
 
-go 1.15
+go 1.24
 
-require github.com/Wariie/go-woxy/tools v0.0.0-20200831140926-4e2b8c3ff239
+require (
+	github.com/Wariie/go-woxy/tools v0.0.0-20200831140926-4e2b8c3ff239
+	github.com/abbot/go-http-auth v0.4.0
+	github.com/mitchellh/mapstructure v1.5.0
+)
+
+require (
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+)
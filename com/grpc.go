@@ -0,0 +1,338 @@
+package com
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gRPC content types
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// gRPC status codes sent by go-woxy
+const (
+	GRPCUnknown          = 2
+	GRPCDeadlineExceeded = 4
+	GRPCPermissionDenied = 7
+	GRPCUnimplemented    = 12
+	GRPCInternal         = 13
+	GRPCUnavailable      = 14
+	GRPCUnauthenticated  = 16
+)
+
+// grpcTrailerFrame - gRPC-Web frame flag carrying trailers
+const grpcTrailerFrame = 0x80
+
+// IsGRPC - Request is a gRPC or gRPC-Web call
+func IsGRPC(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcContentType)
+}
+
+// GRPCTransport - HTTP/2 only transport to module, cleartext (h2c) unless binding is https
+func (pc ProxyConfig) GRPCTransport(binding ServerConfig) *http.Transport {
+	t := pc.Transport(binding)
+	t.Proxy = nil
+	t.ForceAttemptHTTP2 = false
+	t.DisableCompression = true
+	protocols := &http.Protocols{}
+	if binding.PROTOCOL == "https" {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	t.Protocols = protocols
+	return t
+}
+
+// GRPCProxy - gRPC reverse proxy for mod, gRPC-Web calls being translated to gRPC
+func GRPCProxy() HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		routeConfig := ctx.RouteConfig
+		if routeConfig == nil || routeConfig.State() != Online {
+			grpcError(ctx.ResponseWriter, GRPCUnavailable, "module unavailable")
+			return
+		}
+
+		contentType := ctx.Request.Header.Get("Content-Type")
+		web := strings.HasPrefix(contentType, grpcWebContentType)
+		if !IsGRPC(ctx.Request) {
+			grpcError(ctx.ResponseWriter, GRPCUnimplemented, "not a gRPC request")
+			return
+		} else if !web && ctx.Request.ProtoMajor < 2 {
+			http.Error(ctx.ResponseWriter, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+
+		mp := routeConfig.moduleProxy()
+		if mp.grpc == nil {
+			grpcError(ctx.ResponseWriter, GRPCUnimplemented, "not a gRPC module")
+			return
+		}
+
+		//STREAMING CALLS ARE NOT CUT BY WRITE TIMEOUT
+		disableWriteDeadline(ctx.ResponseWriter)
+
+		if web {
+			//HOP-BY-HOP HEADERS ARE REMOVED AFTER DIRECTOR, TE BEING KEPT FROM INCOMING REQUEST
+			ctx.Request.Header.Set("Te", "trailers")
+		} else {
+			contentType = ""
+		}
+
+		//FULL METHOD PATH IS FORWARDED UNCHANGED
+		target := *ctx.URL
+		mp.serveGRPC(ctx, mp.target(&target), contentType)
+	})
+}
+
+func grpcDirector(req *http.Request) {
+	proxyDirector(req)
+	if pr := req.Context().Value(proxyKey{}).(*proxyRequest); pr.grpcWeb != "" {
+		grpcWebRequest(req)
+	}
+}
+
+func grpcModifyResponse(res *http.Response) error {
+	pr := res.Request.Context().Value(proxyKey{}).(*proxyRequest)
+	rewriteResponseHeaders(res.Header, pr.ctx)
+	if err := interceptStatus(res, pr.ctx.RouteConfig); err != nil {
+		return err
+	}
+	if pr.grpcWeb != "" {
+		grpcWebResponse(res, pr.grpcWeb)
+	}
+	return nil
+}
+
+// grpcErrorHandler - Proxy errors answered with a gRPC status
+func grpcErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	pr := r.Context().Value(proxyKey{}).(*proxyRequest)
+
+	//CALL CUT BY MODULE TIMEOUT RATHER THAN BY CLIENT
+	if cause := context.Cause(r.Context()); errors.Is(cause, context.DeadlineExceeded) {
+		err = cause
+	}
+	log.Println("GO-WOXY Core - gRPC error proxying to", pr.ctx.RouteConfig.NAME, ":", err)
+	grpcError(w, grpcStatus(err), http.StatusText(errorStatus(err)))
+}
+
+// grpcStatus - gRPC status of proxy error, intercepted module statuses being mapped as gRPC clients do
+func grpcStatus(err error) int {
+	var upstream *UpstreamError
+	if !errors.As(err, &upstream) && errorStatus(err) == http.StatusGatewayTimeout {
+		return GRPCDeadlineExceeded
+	}
+	switch errorStatus(err) {
+	case http.StatusBadRequest:
+		return GRPCInternal
+	case http.StatusUnauthorized:
+		return GRPCUnauthenticated
+	case http.StatusForbidden:
+		return GRPCPermissionDenied
+	case http.StatusNotFound:
+		return GRPCUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return GRPCUnavailable
+	}
+	return GRPCUnknown
+}
+
+// grpcError - Trailers-only gRPC response
+func grpcError(w http.ResponseWriter, code int, message string) {
+	h := w.Header()
+	h.Set("Content-Type", grpcContentType)
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	h.Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcWebRequest - Turn gRPC-Web request into gRPC one
+func grpcWebRequest(req *http.Request) {
+	contentType := req.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, grpcWebTextContentType) {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{&grpcWebTextReader{r: req.Body, buf: make([]byte, 32*1024)}, req.Body}
+		req.ContentLength = -1
+		req.Header.Del("Content-Length")
+		contentType = strings.TrimPrefix(contentType, grpcWebTextContentType)
+	} else {
+		contentType = strings.TrimPrefix(contentType, grpcWebContentType)
+	}
+	req.Header.Set("Content-Type", grpcContentType+contentType)
+	req.Header.Del("X-Grpc-Web")
+}
+
+// grpcWebTextReader - Decode gRPC-Web text body, clients encoding each frame on its own with its padding
+type grpcWebTextReader struct {
+	r   io.Reader
+	buf []byte
+	in  []byte
+	out []byte
+	err error
+}
+
+func (t *grpcWebTextReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			if t.err == io.EOF && len(t.in) > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, t.err
+		}
+
+		n, err := t.r.Read(t.buf)
+		t.err = err
+		for _, c := range t.buf[:n] {
+			if c != '\r' && c != '\n' {
+				t.in = append(t.in, c)
+			}
+		}
+
+		//WHOLE QUANTA ONLY, REST WAITING FOR NEXT READ
+		q := len(t.in) / 4 * 4
+		if t.out, err = decodeBase64Chunks(t.in[:q]); err != nil {
+			t.err = err
+		}
+		t.in = append(t.in[:0], t.in[q:]...)
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// decodeBase64Chunks - Decode concatenated base64 chunks, padding ending each one
+func decodeBase64Chunks(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, base64.StdEncoding.DecodedLen(len(src)))
+	for len(src) > 0 {
+		end := len(src)
+		if i := bytes.IndexByte(src, '='); i >= 0 {
+			end = (i/4 + 1) * 4
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, src[:end])
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, chunk[:n]...)
+		src = src[end:]
+	}
+	return dst, nil
+}
+
+// grpcWebResponse - Turn gRPC response into gRPC-Web one, trailers being sent as last frame
+func grpcWebResponse(res *http.Response, requestType string) {
+	webType := grpcWebContentType
+	text := strings.HasPrefix(requestType, grpcWebTextContentType)
+	if text {
+		webType = grpcWebTextContentType
+	}
+	if ct := res.Header.Get("Content-Type"); strings.HasPrefix(ct, grpcContentType) {
+		res.Header.Set("Content-Type", webType+strings.TrimPrefix(ct, grpcContentType))
+	}
+
+	res.Header.Del("Trailer")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Body = &grpcWebBody{body: res.Body, res: res, trailer: res.Trailer, text: text}
+	res.Trailer = nil
+}
+
+// grpcWebBody - Module response body followed by trailer frame
+type grpcWebBody struct {
+	body    io.ReadCloser
+	res     *http.Response
+	trailer http.Header
+	text    bool
+	pending []byte
+	done    bool
+}
+
+func (b *grpcWebBody) Read(p []byte) (int, error) {
+	if len(b.pending) > 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+	if b.done {
+		return 0, io.EOF
+	}
+
+	//TEXT MODE CHUNKS ARE ENCODED ONE BY ONE, PADDING INCLUDED
+	buf := p
+	if b.text {
+		buf = make([]byte, len(p)*3/4)
+		if len(buf) == 0 {
+			buf = make([]byte, 3)
+		}
+	}
+	n, err := b.body.Read(buf)
+	if n > 0 {
+		if !b.text {
+			return n, nil
+		}
+		b.pending = []byte(base64.StdEncoding.EncodeToString(buf[:n]))
+		return b.Read(p)
+	}
+	if err == io.EOF {
+		b.done = true
+		b.pending = b.trailerFrame()
+		if b.text {
+			b.pending = []byte(base64.StdEncoding.EncodeToString(b.pending))
+		}
+		return b.Read(p)
+	}
+	return 0, err
+}
+
+func (b *grpcWebBody) Close() error {
+	return b.body.Close()
+}
+
+// trailerFrame - Trailers received at end of body, nothing when sent in headers only
+func (b *grpcWebBody) trailerFrame() []byte {
+	//UNDECLARED TRAILERS ARE SET BY TRANSPORT ON RESPONSE, KEPT AWAY FROM THE REVERSE PROXY
+	trailer := b.trailer.Clone()
+	for k, v := range b.res.Trailer {
+		if trailer == nil {
+			trailer = http.Header{}
+		}
+		trailer[k] = v
+	}
+	b.res.Trailer = nil
+
+	if len(trailer) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var block bytes.Buffer
+	for _, k := range keys {
+		for _, v := range trailer[k] {
+			block.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+block.Len())
+	frame[0] = grpcTrailerFrame
+	binary.BigEndian.PutUint32(frame[1:], uint32(block.Len()))
+	return append(frame, block.Bytes()...)
+}
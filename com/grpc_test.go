@@ -0,0 +1,217 @@
+package com
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// grpcFrame - Length prefixed gRPC message
+func grpcFrame(flag byte, msg string) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+func TestGRPCWebTextReader(t *testing.T) {
+	first, second := grpcFrame(0, "ab"), grpcFrame(0, "hello")
+	want := append(append([]byte{}, first...), second...)
+	if !strings.HasSuffix(base64.StdEncoding.EncodeToString(first), "=") {
+		t.Fatal("first chunk not padded")
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"single chunk", base64.StdEncoding.EncodeToString(want)},
+		{"padded chunks", base64.StdEncoding.EncodeToString(first) + base64.StdEncoding.EncodeToString(second)},
+		{"line breaks", base64.StdEncoding.EncodeToString(first) + "\r\n" + base64.StdEncoding.EncodeToString(second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &grpcWebTextReader{r: iotest.OneByteReader(strings.NewReader(tt.body)), buf: make([]byte, 8)}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded %x, want %x", got, want)
+			}
+		})
+	}
+
+	r := &grpcWebTextReader{r: strings.NewReader("QUJD!"), buf: make([]byte, 8)}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("truncated body accepted")
+	}
+}
+
+// grpcModule - h2c module echoing request frames, trailers carrying status
+func grpcModule(t *testing.T) (*Router, *RouteConfig) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc+proto" || r.Header.Get("Te") != "trailers" {
+			t.Errorf("module got %s %s te=%q", r.Proto, r.Header.Get("Content-Type"), r.Header.Get("Te"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "ok")
+	}))
+	backend.Config.Protocols = &http.Protocols{}
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	host, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	rc := &RouteConfig{NAME: "grpc", TYPES: "grpc", STATE: Online, BINDING: ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}}
+	r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+	r.Handle("/", GRPCProxy(), rc, &Route{FROM: "/", TO: "/"})
+	return r, rc
+}
+
+func TestGRPCWebTranslation(t *testing.T) {
+	r, _ := grpcModule(t)
+	msg := grpcFrame(0, "hello")
+	trailers := grpcFrame(grpcTrailerFrame, "grpc-message: ok\r\ngrpc-status: 0\r\n")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		decode      func(string) ([]byte, error)
+	}{
+		{"binary", "application/grpc-web+proto", string(msg), func(s string) ([]byte, error) { return []byte(s), nil }},
+		{"text", "application/grpc-web-text+proto", base64.StdEncoding.EncodeToString(msg), func(s string) ([]byte, error) {
+			return decodeBase64Chunks([]byte(s))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/echo.Echo/Say", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("X-Grpc-Web", "1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d : %s", w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("content type %q, want %q", ct, tt.contentType)
+			}
+
+			//MESSAGE FRAME THEN TRAILERS FRAME
+			got, err := tt.decode(w.Body.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]byte{}, msg...), trailers...)
+			if !bytes.Equal(got, want) {
+				t.Errorf("body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// grpcCall - gRPC call to router, gRPC status read from trailers-only answer
+func grpcCall(r *Router, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, method, bytes.NewReader(grpcFrame(0, "hello")))
+	req.ProtoMajor, req.ProtoMinor = 2, 0
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGRPCSharedProxy(t *testing.T) {
+	var conns int64
+	var hits int64
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		switch r.URL.Path {
+		case "/echo.Echo/Slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		case "/echo.Echo/Missing":
+			http.NotFound(w, r)
+			return
+		case "/echo.Echo/Fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	backend.Config.Protocols = &http.Protocols{}
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	host, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	rc := &RouteConfig{NAME: "grpc", TYPES: "grpc", STATE: Online, BINDING: ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"},
+		ERRORS: ErrorPolicy{INTERCEPT: []string{"404"}},
+		PROXY:  ProxyConfig{TIMEOUT: 50 * time.Millisecond, CIRCUIT: CircuitConfig{FAILURES: 2, COOLDOWN: time.Minute}}}
+	r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+	r.Handle("/", GRPCProxy(), rc, &Route{FROM: "/", TO: "/"})
+
+	//CALLS SHARE MODULE PROXY AND ITS CONNECTION
+	mp := rc.moduleProxy()
+	for i := 0; i < 3; i++ {
+		if w := grpcCall(r, "/echo.Echo/Say"); w.Code != http.StatusOK || w.Header().Get("Grpc-Status") != "0" {
+			t.Fatalf("call %d : %d status %q", i, w.Code, w.Header().Get("Grpc-Status"))
+		}
+	}
+	if rc.moduleProxy() != mp || mp.grpc == nil {
+		t.Error("gRPC proxy not shared across calls")
+	}
+	if n := atomic.LoadInt64(&conns); n != 1 {
+		t.Errorf("module connections = %d, want 1", n)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		status string
+	}{
+		{"module timeout", "/echo.Echo/Slow", "4"},
+		{"intercepted status", "/echo.Echo/Missing", "12"},
+		{"failing module", "/echo.Echo/Fail", ""},
+		{"failing module opening circuit", "/echo.Echo/Fail", ""},
+		{"circuit open", "/echo.Echo/Say", "14"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := grpcCall(r, tt.method); w.Header().Get("Grpc-Status") != tt.status {
+				t.Errorf("status %d grpc-status %q, want %q", w.Code, w.Header().Get("Grpc-Status"), tt.status)
+			}
+		})
+	}
+	if n := atomic.LoadInt64(&hits); n != 7 {
+		t.Errorf("module hits = %d, want 7, open circuit not reaching module", n)
+	}
+	if s := rc.Upstream(); s.CIRCUIT != CircuitOpen {
+		t.Errorf("upstream stats %+v", s)
+	}
+}
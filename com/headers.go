@@ -0,0 +1,203 @@
+package com
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// HeaderRequestID - Request identifier header, kept from trusted proxies
+const HeaderRequestID = "X-Request-Id"
+
+// HeaderRules - Headers changed on requests to modules and on their responses
+type HeaderRules struct {
+	REQUEST  HeaderOps
+	RESPONSE HeaderOps
+}
+
+// HeaderOps - Headers removed, then set, then added
+// Values are templates reading HeaderData (example : '{{.ClientIP}}', '{{index .Params 0}}')
+type HeaderOps struct {
+	ADD    map[string]string
+	REMOVE []string
+	SET    map[string]string
+}
+
+// HeaderData - Values available in header templates
+type HeaderData struct {
+	ClientIP  string
+	Host      string
+	Module    string
+	Params    []string
+	RequestID string
+	User      string
+}
+
+// headerValue - Compiled header value, literal ones skipping template execution
+type headerValue struct {
+	name    string
+	literal string
+	tmpl    *template.Template
+}
+
+// headerOps - Compiled header operations
+type headerOps struct {
+	remove []string
+	set    []headerValue
+	add    []headerValue
+}
+
+// HeaderRewriter - Compiled module and route header rules
+type HeaderRewriter struct {
+	request   []headerOps
+	response  []headerOps
+	requestID bool
+}
+
+// NewHeaderRewriter - Compile rules in order, route rules coming after module ones, nil without rules
+func NewHeaderRewriter(rules ...HeaderRules) (*HeaderRewriter, error) {
+	hr := &HeaderRewriter{}
+	for _, r := range rules {
+		req, err := hr.compile(r.REQUEST)
+		if err != nil {
+			return nil, err
+		}
+		res, err := hr.compile(r.RESPONSE)
+		if err != nil {
+			return nil, err
+		}
+		if req != nil {
+			hr.request = append(hr.request, *req)
+		}
+		if res != nil {
+			hr.response = append(hr.response, *res)
+		}
+	}
+	if len(hr.request) == 0 && len(hr.response) == 0 {
+		return nil, nil
+	}
+	return hr, nil
+}
+
+func (hr *HeaderRewriter) compile(ops HeaderOps) (*headerOps, error) {
+	if len(ops.ADD) == 0 && len(ops.REMOVE) == 0 && len(ops.SET) == 0 {
+		return nil, nil
+	}
+	compiled := &headerOps{remove: ops.REMOVE}
+	var err error
+	if compiled.set, err = hr.compileValues(ops.SET); err != nil {
+		return nil, err
+	}
+	if compiled.add, err = hr.compileValues(ops.ADD); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+func (hr *HeaderRewriter) compileValues(values map[string]string) ([]headerValue, error) {
+	compiled := make([]headerValue, 0, len(values))
+	for name, v := range values {
+		hv := headerValue{name: http.CanonicalHeaderKey(name), literal: v}
+		if strings.Contains(v, "{{") {
+			tmpl, err := template.New(name).Option("missingkey=error").Parse(v)
+			if err != nil {
+				return nil, err
+			}
+			hv.tmpl = tmpl
+			hr.requestID = hr.requestID || strings.Contains(v, "RequestID")
+		}
+		compiled = append(compiled, hv)
+	}
+	return compiled, nil
+}
+
+// Handler - Make rules available to module proxy, handler being returned as is without rules
+func (hr *HeaderRewriter) Handler(next HandlerFunc) HandlerFunc {
+	if hr == nil {
+		return next
+	}
+	return HandlerFunc(func(ctx *Context) {
+		ctx.headers = hr
+		next(ctx)
+	})
+}
+
+// data - Template values of request
+func (hr *HeaderRewriter) data(ctx *Context) *HeaderData {
+	data := &HeaderData{ClientIP: ctx.ClientIP, Host: ctx.Request.Host, Params: ctx.Params, User: ctx.User}
+	if ctx.RouteConfig != nil {
+		data.Module = ctx.RouteConfig.NAME
+	}
+	if hr.requestID {
+		data.RequestID = ctx.RequestID()
+	}
+	return data
+}
+
+// apply - Run operations on headers
+func (hr *HeaderRewriter) apply(h http.Header, ops []headerOps, ctx *Context) {
+	var data *HeaderData
+	value := func(hv headerValue) (string, bool) {
+		if hv.tmpl == nil {
+			return hv.literal, true
+		}
+		if data == nil {
+			data = hr.data(ctx)
+		}
+		//FAILING TEMPLATE SKIPS HEADER RATHER THAN SENDING IT EMPTY
+		var sb strings.Builder
+		if err := hv.tmpl.Execute(&sb, data); err != nil {
+			log.Println("GO-WOXY Core - Error executing header", hv.name, "template :", err)
+			return "", false
+		}
+		return sb.String(), true
+	}
+
+	for _, op := range ops {
+		for _, name := range op.remove {
+			h.Del(name)
+		}
+		for _, hv := range op.set {
+			if v, ok := value(hv); ok {
+				h.Set(hv.name, v)
+			}
+		}
+		for _, hv := range op.add {
+			if v, ok := value(hv); ok {
+				h.Add(hv.name, v)
+			}
+		}
+	}
+}
+
+// rewriteRequestHeaders - Apply request rules on request to module
+func rewriteRequestHeaders(h http.Header, ctx *Context) {
+	if ctx.headers != nil {
+		ctx.headers.apply(h, ctx.headers.request, ctx)
+	}
+}
+
+// rewriteResponseHeaders - Apply response rules on module response
+func rewriteResponseHeaders(h http.Header, ctx *Context) {
+	if ctx.headers != nil {
+		ctx.headers.apply(h, ctx.headers.response, ctx)
+	}
+}
+
+// RequestID - Request identifier, given by a trusted proxy or generated once
+func (ctx *Context) RequestID() string {
+	if ctx.requestID != "" {
+		return ctx.requestID
+	}
+	if id := ctx.Request.Header.Get(HeaderRequestID); id != "" && ctx.trustedPeer {
+		ctx.requestID = id
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	ctx.requestID = hex.EncodeToString(b)
+	return ctx.requestID
+}
@@ -0,0 +1,100 @@
+package com
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+type ipFilterOptions struct {
+	ALLOW []string
+	DENY  []string
+}
+
+// ipFilterMiddleware - Allow or deny requests depending on client network
+func ipFilterMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	var opts ipFilterOptions
+	if err := DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+
+	filter, err := NewIPFilter(opts.ALLOW, opts.DENY)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *Context) {
+			if !filter.Allowed(net.ParseIP(ctx.ClientIP)) {
+				ctx.Text(http.StatusForbidden, "GO-WOXY Core - Forbidden")
+				return
+			}
+			next.Handle(ctx)
+		})
+	}, nil
+}
+
+// IPFilter - CIDR allow and deny lists
+type IPFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter - Parse allow and deny lists, single addresses are accepted
+func NewIPFilter(allow []string, deny []string) (*IPFilter, error) {
+	var f IPFilter
+	var err error
+	if f.allow, err = ParseCIDRs(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = ParseCIDRs(deny); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Allowed - Deny list is checked first, then ip must match allow list if not empty
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	if ContainsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || ContainsIP(f.allow, ip)
+}
+
+// ParseCIDRs - Parse list of CIDR or plain IP addresses
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: s}
+			}
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ContainsIP - Check if ip belongs to one of nets
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package com
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// rewriteLocations - Module urls of response headers seen from public route prefix
+func rewriteLocations(h http.Header, ctx *Context, base *url.URL) {
+	if ctx.Route == nil || (!ctx.Route.REWRITE_LOCATION && !ctx.Route.REWRITE_COOKIES) {
+		return
+	}
+	rw := ctx.routeRewriter()
+
+	if ctx.Route.REWRITE_LOCATION {
+		for _, name := range []string{"Location", "Content-Location"} {
+			if v := h.Get(name); v != "" {
+				h.Set(name, publicURL(v, ctx, rw, base))
+			}
+		}
+		if v := h.Get("Refresh"); v != "" {
+			h.Set("Refresh", publicRefresh(v, ctx, rw, base))
+		}
+	}
+
+	if ctx.Route.REWRITE_COOKIES {
+		cookies := h.Values("Set-Cookie")
+		for i, c := range cookies {
+			cookies[i] = publicCookie(c, rw, base)
+		}
+	}
+}
+
+// publicURL - Url given by module with its path under route prefix and public host
+func publicURL(raw string, ctx *Context, rw *Rewriter, base *url.URL) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	//RELATIVE PATHS AND ABSOLUTE URLS OF MODULE OR REQUESTED HOST
+	if u.Host != "" {
+		if !strings.EqualFold(u.Host, base.Host) && !strings.EqualFold(u.Host, ctx.Request.Host) {
+			return raw
+		}
+		u.Scheme = requestScheme(ctx)
+		u.Host = ctx.Request.Host
+	} else if u.Scheme != "" || !strings.HasPrefix(u.Path, "/") {
+		return raw
+	}
+
+	p, ok := rw.Reverse(u.EscapedPath())
+	if !ok {
+		return u.String()
+	}
+	if path, err := url.PathUnescape(p); err == nil {
+		u.Path, u.RawPath = path, p
+	}
+	return u.String()
+}
+
+// publicRefresh - Refresh header ('5; url=/next') with public url
+func publicRefresh(v string, ctx *Context, rw *Rewriter, base *url.URL) string {
+	i := strings.Index(strings.ToLower(v), "url=")
+	if i < 0 {
+		return v
+	}
+	target := strings.Trim(strings.TrimSpace(v[i+4:]), `'"`)
+	return v[:i+4] + publicURL(target, ctx, rw, base)
+}
+
+// publicCookie - Set-Cookie with Path under route prefix, module Domain making it a public host cookie
+func publicCookie(c string, rw *Rewriter, base *url.URL) string {
+	parts := strings.Split(c, ";")
+	attrs := parts[:1]
+	for _, attr := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(attr), "=", 2)
+		switch strings.ToLower(kv[0]) {
+		case "path":
+			if len(kv) == 2 {
+				if p, ok := rw.Reverse(kv[1]); ok {
+					//MODULE ROOT COOKIE MATCHING THE PREFIX ITSELF
+					if p != "/" && (kv[1] == "/" || kv[1] == rw.upstream+"/") {
+						p = strings.TrimSuffix(p, "/")
+					}
+					attr = " Path=" + p
+				}
+			}
+		case "domain":
+			if len(kv) == 2 && strings.EqualFold(strings.TrimPrefix(kv[1], "."), base.Hostname()) {
+				continue
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+	return strings.Join(attrs, ";")
+}
@@ -0,0 +1,123 @@
+package com
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaintenanceConfig - Requests answered with a maintenance notice, allowed clients and bypass cookie holders excepted
+type MaintenanceConfig struct {
+	ALLOW         []string
+	BYPASS_COOKIE string
+	ENABLED       bool
+	MESSAGE       string
+	PAGE          string
+	RETRY_AFTER   time.Duration
+}
+
+// Maintenance - Compiled maintenance config
+type Maintenance struct {
+	allow       []*net.IPNet
+	cookieName  string
+	cookieValue string
+	message     string
+	page        *template.Template
+	retryAfter  int
+}
+
+// DefaultMaintenanceRetryAfter - Delay given to clients without retry_after
+var DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// NewMaintenance - Compile maintenance config, nil when disabled
+func NewMaintenance(mc MaintenanceConfig) (*Maintenance, error) {
+	if !mc.ENABLED {
+		return nil, nil
+	}
+
+	allow, err := ParseCIDRs(mc.ALLOW)
+	if err != nil {
+		return nil, err
+	}
+	m := &Maintenance{allow: allow, message: mc.MESSAGE}
+	if m.message == "" {
+		m.message = "Down for maintenance"
+	}
+
+	if mc.BYPASS_COOKIE != "" {
+		kv := strings.SplitN(mc.BYPASS_COOKIE, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, errors.New("maintenance bypass_cookie must be 'name=value'")
+		}
+		m.cookieName, m.cookieValue = kv[0], kv[1]
+	}
+
+	if mc.PAGE != "" {
+		if m.page, err = template.ParseFiles(mc.PAGE); err != nil {
+			return nil, err
+		}
+	}
+
+	retryAfter := mc.RETRY_AFTER
+	if retryAfter <= 0 {
+		retryAfter = DefaultMaintenanceRetryAfter
+	}
+	m.retryAfter = int(retryAfter.Seconds())
+	return m, nil
+}
+
+// Bypass - Request is let through maintenance
+func (m *Maintenance) Bypass(ctx *Context) bool {
+	if ip := net.ParseIP(ctx.ClientIP); ip != nil && ContainsIP(m.allow, ip) {
+		return true
+	}
+	if m.cookieName != "" {
+		if c, err := ctx.Request.Cookie(m.cookieName); err == nil {
+			return subtle.ConstantTimeCompare([]byte(c.Value), []byte(m.cookieValue)) == 1
+		}
+	}
+	return false
+}
+
+// Handler - Maintenance notice, module error pages being used without page
+func (m *Maintenance) Handler(rc *RouteConfig) HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		ctx.ResponseWriter.Header().Set("Cache-Control", "no-store")
+		data := ErrorPage{Title: "Maintenance", Code: http.StatusServiceUnavailable, Message: m.message, RetryAfter: m.retryAfter}
+		tmpl := m.page
+		if tmpl == nil {
+			tmpl = errorTemplate(rc, data.Code)
+		}
+		writeErrorPage(ctx.ResponseWriter, ctx.Request, tmpl, data)
+	})
+}
+
+// SetMaintenance - Put whole router under maintenance, nil to end it
+func (r *Router) SetMaintenance(m *Maintenance) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.maintenance = m
+}
+
+// Maintenance - Whole router maintenance, nil when not under maintenance
+func (r *Router) Maintenance() *Maintenance {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.maintenance
+}
+
+// maintenanceHandler - Notice of router under maintenance, nil when request can go on
+// Control routes (/cmd, /connect), bound without module, stay reachable to end maintenance and ping modules
+func (r *Router) maintenanceHandler(ctx *Context, rt *PatternRoute) HandlerFunc {
+	r.mux.RLock()
+	m := r.maintenance
+	r.mux.RUnlock()
+	if m == nil || (rt != nil && rt.RouteConfig == nil) || m.Bypass(ctx) {
+		return nil
+	}
+	return m.Handler(nil)
+}
@@ -0,0 +1,53 @@
+package com
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGlobalMaintenance(t *testing.T) {
+	r, rc := testRouter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), Route{FROM: "/app", TO: "/"})
+	control := HandlerFunc(func(ctx *Context) { ctx.Text(http.StatusOK, "Maintenance off") })
+	r.Handle("/cmd", control, nil, nil)
+	r.Handle("/connect", control, nil, nil)
+
+	m, err := NewMaintenance(MaintenanceConfig{ENABLED: true, ALLOW: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetMaintenance(m)
+
+	tests := []struct {
+		name   string
+		path   string
+		remote string
+		code   int
+	}{
+		{"command endpoint", "/cmd", "203.0.113.7:1234", http.StatusOK},
+		{"module connection endpoint", "/connect", "203.0.113.7:1234", http.StatusOK},
+		{"module route", "/app/index", "203.0.113.7:1234", http.StatusServiceUnavailable},
+		{"unknown path", "/other", "203.0.113.7:1234", http.StatusServiceUnavailable},
+		{"allowed client", "/app/index", "10.0.0.1:1234", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.RemoteAddr = tt.remote
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("status %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+
+	//MODULE MAINTENANCE STILL APPLIES WITHOUT GLOBAL ONE
+	r.SetMaintenance(nil)
+	rc.maintenance = m
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/index", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("module maintenance : %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,223 @@
+package com
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	auth "github.com/abbot/go-http-auth"
+	"github.com/mitchellh/mapstructure"
+)
+
+// MiddlewareConfig - Middleware declaration from configuration
+type MiddlewareConfig struct {
+	NAME    string
+	OPTIONS map[string]interface{}
+}
+
+// MiddlewareFactory - Build a middleware from its configuration options
+type MiddlewareFactory func(options map[string]interface{}) (MiddlewareFunc, error)
+
+var (
+	factoriesMux sync.RWMutex
+	factories    = map[string]MiddlewareFactory{}
+	zoned        = map[string]bool{}
+)
+
+func init() {
+	RegisterMiddleware("auth", authMiddleware)
+	RegisterMiddleware("headers", headersMiddleware)
+	RegisterZonedMiddleware("rate-limit", rateLimitMiddleware)
+	RegisterMiddleware("cors", corsMiddleware)
+	RegisterMiddleware("compress", compressMiddleware)
+	RegisterMiddleware("ip-filter", ipFilterMiddleware)
+}
+
+// RegisterMiddleware - Register a middleware factory usable from configuration
+// Registering an existing name replaces the previous factory
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	factoriesMux.Lock()
+	defer factoriesMux.Unlock()
+	factories[name] = factory
+	delete(zoned, name)
+}
+
+// RegisterZonedMiddleware - Register a middleware factory keeping state shared by middlewares of the same zone option
+func RegisterZonedMiddleware(name string, factory MiddlewareFactory) {
+	RegisterMiddleware(name, factory)
+	factoriesMux.Lock()
+	defer factoriesMux.Unlock()
+	zoned[name] = true
+}
+
+// WithZone - Default zone option of zoned middlewares, unique per entry so only chains built from the same entry share state
+func WithZone(configs []MiddlewareConfig, zone string) []MiddlewareConfig {
+	factoriesMux.RLock()
+	defer factoriesMux.RUnlock()
+	out := make([]MiddlewareConfig, len(configs))
+	for i, cfg := range configs {
+		out[i] = cfg
+		if !zoned[cfg.NAME] {
+			continue
+		}
+		options := map[string]interface{}{"zone": zone + "#" + strconv.Itoa(i)}
+		for k, v := range cfg.OPTIONS {
+			options[k] = v
+		}
+		out[i].OPTIONS = options
+	}
+	return out
+}
+
+// NewMiddleware - Build a middleware from the registered factory matching the config name
+func NewMiddleware(cfg MiddlewareConfig) (MiddlewareFunc, error) {
+	factoriesMux.RLock()
+	factory, ok := factories[cfg.NAME]
+	factoriesMux.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown middleware \"" + cfg.NAME + "\"")
+	}
+
+	mw, err := factory(cfg.OPTIONS)
+	if err != nil {
+		return nil, errors.New("middleware \"" + cfg.NAME + "\" : " + err.Error())
+	}
+	return mw, nil
+}
+
+// Chain - Ordered list of middlewares, first one being the outermost
+type Chain []MiddlewareFunc
+
+// NewChain - Compile middleware configurations into a chain
+func NewChain(configs ...MiddlewareConfig) (Chain, error) {
+	chain := make(Chain, 0, len(configs))
+	for _, cfg := range configs {
+		mw, err := NewMiddleware(cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, mw)
+	}
+	return chain, nil
+}
+
+// Then - Wrap handler with every middleware of the chain
+func (c Chain) Then(h Handler) Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// DecodeOptions - Decode middleware options into out struct
+// Lists and maps given in options replace default ones instead of being merged with them
+func DecodeOptions(options map[string]interface{}, out interface{}) error {
+	if options == nil {
+		return nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		ZeroFields:       true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(options)
+}
+
+type authOptions struct {
+	HTPASSWD string
+	REALM    string
+}
+
+// authMiddleware - Basic authentication against an htpasswd file
+func authMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	opts := authOptions{HTPASSWD: ".htpasswd", REALM: "go-woxy"}
+	if err := DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(opts.HTPASSWD); os.IsNotExist(err) {
+		return nil, errors.New(opts.HTPASSWD + " file not found")
+	}
+	a := auth.NewBasicAuthenticator(opts.REALM, auth.HtpasswdFileProvider(opts.HTPASSWD))
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *Context) {
+			user := a.CheckAuth(ctx.Request)
+			if user == "" {
+				ctx.ResponseWriter.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(a.Realm))
+				ctx.ResponseWriter.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			ctx.User = user
+			next.Handle(ctx)
+		})
+	}, nil
+}
+
+// headersMiddleware - Request and response header rules, same as module and route headers
+func headersMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	var rules HeaderRules
+	if err := DecodeOptions(options, &rules); err != nil {
+		return nil, err
+	}
+	hr, err := NewHeaderRewriter(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next Handler) Handler {
+		if hr == nil {
+			return next
+		}
+		return HandlerFunc(func(ctx *Context) {
+			hr.apply(ctx.Request.Header, hr.request, ctx)
+			if len(hr.response) > 0 {
+				ctx.ResponseWriter = &headerWriter{ResponseWriter: ctx.ResponseWriter, ctx: ctx, hr: hr}
+			}
+			next.Handle(ctx)
+		})
+	}, nil
+}
+
+// headerWriter - ResponseWriter applying response rules right before headers are sent
+type headerWriter struct {
+	http.ResponseWriter
+	ctx         *Context
+	hr          *HeaderRewriter
+	wroteHeader bool
+}
+
+func (w *headerWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.hr.apply(w.ResponseWriter.Header(), w.hr.response, w.ctx)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap - Give access to the underlying ResponseWriter
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
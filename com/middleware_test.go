@@ -0,0 +1,308 @@
+package com
+
+import (
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serveChain - Run request through chain, handler answering body with content type
+func serveChain(t *testing.T, chain Chain, r *http.Request, contentType string, body string) (*httptest.ResponseRecorder, *Context) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ctx := &Context{Request: r, ResponseWriter: w}
+	chain.Then(HandlerFunc(func(ctx *Context) {
+		ctx.ResponseWriter.Header().Set("Content-Type", contentType)
+		ctx.ResponseWriter.WriteHeader(http.StatusOK)
+		io.WriteString(ctx.ResponseWriter, body)
+	})).Handle(ctx)
+	return w, ctx
+}
+
+func newChain(t *testing.T, configs ...MiddlewareConfig) Chain {
+	t.Helper()
+	chain, err := NewChain(configs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestMiddlewareRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		factoriesMux.Lock()
+		delete(factories, "test-registry")
+		factoriesMux.Unlock()
+	})
+	factory := func(value string) MiddlewareFactory {
+		return func(options map[string]interface{}) (MiddlewareFunc, error) {
+			return func(next Handler) Handler {
+				return HandlerFunc(func(ctx *Context) {
+					ctx.ResponseWriter.Header().Set("X-Test", value)
+					next.Handle(ctx)
+				})
+			}, nil
+		}
+	}
+
+	//REGISTERING AGAIN REPLACES FACTORY
+	RegisterMiddleware("test-registry", factory("first"))
+	RegisterMiddleware("test-registry", factory("second"))
+	w, _ := serveChain(t, newChain(t, MiddlewareConfig{NAME: "test-registry"}), httptest.NewRequest(http.MethodGet, "/", nil), "text/plain", "ok")
+	if got := w.Header().Get("X-Test"); got != "second" {
+		t.Errorf("X-Test = %q, want second", got)
+	}
+
+	tests := []struct {
+		name string
+		cfg  MiddlewareConfig
+		err  string
+	}{
+		{"unknown name", MiddlewareConfig{NAME: "missing"}, `unknown middleware "missing"`},
+		{"bad option type", MiddlewareConfig{NAME: "cors", OPTIONS: map[string]interface{}{"max_age": "abc"}}, `middleware "cors" : `},
+		{"bad compress level", MiddlewareConfig{NAME: "compress", OPTIONS: map[string]interface{}{"level": 42}}, `middleware "compress" : `},
+		{"missing htpasswd", MiddlewareConfig{NAME: "auth", OPTIONS: map[string]interface{}{"htpasswd": filepath.Join(t.TempDir(), "missing")}}, `middleware "auth" : `},
+		{"bad header template", MiddlewareConfig{NAME: "headers", OPTIONS: map[string]interface{}{"request": map[string]interface{}{"set": map[string]interface{}{"X-User": "{{.User"}}}}, `middleware "headers" : `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewChain(MiddlewareConfig{NAME: "compress"}, tt.cfg)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("err = %v, want prefix %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	t.Cleanup(func() {
+		factoriesMux.Lock()
+		delete(factories, "test-trace")
+		factoriesMux.Unlock()
+	})
+	RegisterMiddleware("test-trace", func(options map[string]interface{}) (MiddlewareFunc, error) {
+		var opts struct{ STEP string }
+		if err := DecodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx *Context) {
+				ctx.Request.Header.Add("X-Trace", opts.STEP)
+				next.Handle(ctx)
+			})
+		}, nil
+	})
+
+	//MODULE MIDDLEWARES BEFORE ROUTE ONES, AS CORE HOOKS THEM
+	module := []MiddlewareConfig{{NAME: "test-trace", OPTIONS: map[string]interface{}{"step": "module"}}}
+	route := []MiddlewareConfig{
+		{NAME: "test-trace", OPTIONS: map[string]interface{}{"step": "route-1"}},
+		{NAME: "test-trace", OPTIONS: map[string]interface{}{"step": "route-2"}},
+	}
+	chain := newChain(t, append(WithZone(module, "mod"), WithZone(route, "mod /app")...)...)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	serveChain(t, chain, r, "text/plain", "ok")
+	if got := strings.Join(r.Header.Values("X-Trace"), ","); got != "module,route-1,route-2" {
+		t.Errorf("order = %s", got)
+	}
+
+	if got := Chain(nil).Then(HandlerFunc(func(ctx *Context) {})); got == nil {
+		t.Error("empty chain lost handler")
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	chain := newChain(t, MiddlewareConfig{NAME: "cors", OPTIONS: map[string]interface{}{
+		"origins": []interface{}{"https://example.com"},
+		"methods": []interface{}{"GET", "PUT"},
+		"expose":  []interface{}{"grpc-status"},
+		"max_age": 600,
+	}})
+	credentials := newChain(t, MiddlewareConfig{NAME: "cors", OPTIONS: map[string]interface{}{"credentials": true}})
+	wildcard := newChain(t, MiddlewareConfig{NAME: "cors"})
+
+	tests := []struct {
+		name    string
+		chain   Chain
+		method  string
+		origin  string
+		preflit bool
+		code    int
+		headers map[string]string
+	}{
+		{"no origin", chain, http.MethodGet, "", false, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"}},
+		{"origin not allowed", chain, http.MethodGet, "https://evil.com", false, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"allowed origin", chain, http.MethodGet, "https://EXAMPLE.com", false, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": "https://EXAMPLE.com", "Access-Control-Expose-Headers": "grpc-status"}},
+		{"preflight", chain, http.MethodOptions, "https://example.com", true, http.StatusNoContent, map[string]string{"Access-Control-Allow-Methods": "GET, PUT", "Access-Control-Allow-Headers": "X-Custom", "Access-Control-Max-Age": "600"}},
+		{"options without preflight", chain, http.MethodOptions, "https://example.com", false, http.StatusOK, map[string]string{"Access-Control-Allow-Methods": ""}},
+		{"wildcard", wildcard, http.MethodGet, "https://any.com", false, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""}},
+		{"credentials echo origin", credentials, http.MethodGet, "https://any.com", false, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": "https://any.com", "Access-Control-Allow-Credentials": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflit {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+				r.Header.Set("Access-Control-Request-Headers", "X-Custom")
+			}
+			w, _ := serveChain(t, tt.chain, r, "text/plain", "ok")
+			if w.Code != tt.code {
+				t.Errorf("status %d, want %d", w.Code, tt.code)
+			}
+			for k, v := range tt.headers {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	chain := newChain(t, MiddlewareConfig{NAME: "compress"})
+	body := strings.Repeat("go-woxy ", 100)
+
+	tests := []struct {
+		name        string
+		encoding    string
+		contentType string
+		route       *Route
+		compressed  bool
+	}{
+		{"text compressed", "gzip, deflate", "text/html; charset=utf-8", nil, true},
+		{"json compressed", "gzip", "application/json", nil, true},
+		{"client without gzip", "", "text/html", nil, false},
+		{"image left alone", "gzip", "image/png", nil, false},
+		{"event stream left alone", "gzip", "text/event-stream", nil, false},
+		{"streaming route left alone", "gzip", "text/html", &Route{STREAMING: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.encoding != "" {
+				r.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			ctx := &Context{Request: r, ResponseWriter: w, Route: tt.route}
+			chain.Then(HandlerFunc(func(ctx *Context) {
+				ctx.ResponseWriter.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(ctx.ResponseWriter, body)
+			})).Handle(ctx)
+
+			if got := w.Header().Get("Content-Encoding") == "gzip"; got != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", got, tt.compressed)
+			}
+			if !tt.compressed {
+				if w.Body.String() != body {
+					t.Error("body changed")
+				}
+				return
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary = %q", w.Header().Get("Vary"))
+			}
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := io.ReadAll(gz); string(b) != body {
+				t.Error("decompressed body differs")
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	sum := sha1.Sum([]byte("secret"))
+	htpasswd := filepath.Join(t.TempDir(), ".htpasswd")
+	if err := os.WriteFile(htpasswd, []byte("alice:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	chain := newChain(t, MiddlewareConfig{NAME: "auth", OPTIONS: map[string]interface{}{"htpasswd": htpasswd, "realm": "test"}})
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		code     int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "alice", "nope", http.StatusUnauthorized},
+		{"unknown user", "bob", "secret", http.StatusUnauthorized},
+		{"valid credentials", "alice", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w, ctx := serveChain(t, chain, r, "text/plain", "ok")
+			if w.Code != tt.code {
+				t.Fatalf("status %d, want %d", w.Code, tt.code)
+			}
+			if tt.code == http.StatusUnauthorized {
+				if got := w.Header().Get("WWW-Authenticate"); got != `Basic realm="test"` {
+					t.Errorf("WWW-Authenticate = %q", got)
+				}
+				if w.Body.String() == "ok" {
+					t.Error("handler reached without credentials")
+				}
+			} else if ctx.User != tt.user {
+				t.Errorf("user = %q, want %q", ctx.User, tt.user)
+			}
+		})
+	}
+}
+
+func TestHeadersMiddleware(t *testing.T) {
+	//NESTED OPTIONS AS DECODED FROM YAML
+	chain := newChain(t, MiddlewareConfig{NAME: "headers", OPTIONS: map[string]interface{}{
+		"request": map[interface{}]interface{}{
+			"set":    map[interface{}]interface{}{"X-Client": "{{.ClientIP}}"},
+			"remove": []interface{}{"Cookie"},
+		},
+		"response": map[interface{}]interface{}{
+			"set":    map[interface{}]interface{}{"X-Frame-Options": "DENY"},
+			"remove": []interface{}{"Server"},
+		},
+	}})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Cookie", "a=b")
+	w := httptest.NewRecorder()
+	ctx := &Context{Request: r, ResponseWriter: w, ClientIP: "203.0.113.7"}
+	chain.Then(HandlerFunc(func(ctx *Context) {
+		if got := ctx.Request.Header.Get("X-Client"); got != "203.0.113.7" {
+			t.Errorf("X-Client = %q", got)
+		}
+		if ctx.Request.Header.Get("Cookie") != "" {
+			t.Error("Cookie not removed")
+		}
+		ctx.ResponseWriter.Header().Set("Server", "module")
+		io.WriteString(ctx.ResponseWriter, "ok")
+	})).Handle(ctx)
+
+	if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Errorf("X-Frame-Options = %q", got)
+	}
+	if got := w.Header().Get("Server"); got != "" {
+		t.Errorf("Server = %q, want removed", got)
+	}
+
+	//NO RULES, NOTHING WRAPPED
+	w, _ = serveChain(t, newChain(t, MiddlewareConfig{NAME: "headers"}), httptest.NewRequest(http.MethodGet, "/", nil), "text/plain", "ok")
+	if w.Body.String() != "ok" {
+		t.Errorf("body %q", w.Body.String())
+	}
+}
@@ -3,9 +3,14 @@ package com
 import (
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"regexp"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Handler interface {
@@ -22,6 +27,7 @@ func (h HandlerFunc) Handle(ctx *Context) {
 // PatternRoute - Module route
 type PatternRoute struct {
 	Pattern     *regexp.Regexp
+	Host        string
 	Handler     HandlerFunc
 	RouteConfig *RouteConfig
 	Route       *Route
@@ -44,9 +50,14 @@ type middleware interface {
 
 // Router - Server router containing routes
 type Router struct {
-	Routes       []PatternRoute
-	DefaultRoute HandlerFunc
-	Middlewares  []middleware
+	Routes         []PatternRoute
+	DefaultRoute   HandlerFunc
+	Middlewares    []middleware
+	TrustedProxies []*net.IPNet
+	canonical      *CanonicalConfig
+	maintenance    *Maintenance
+	mux            sync.RWMutex
+	redirects      []redirect
 }
 
 // NewRouter - Init new router instance
@@ -61,36 +72,97 @@ func (r *Router) Handler(pattern string, handler Handler, routeConfig *RouteConf
 	r.Handle(pattern, handler.Handle, routeConfig, ro)
 }
 
-// Handle - Handle new router into router
+// Handle - Handle new router into router, replacing route with same pattern and host
 func (r *Router) Handle(pattern string, handler HandlerFunc, routeConfig *RouteConfig, ro *Route) {
 	re := regexp.MustCompile(pattern)
 	route := PatternRoute{Pattern: re, Handler: handler, RouteConfig: routeConfig, Route: ro}
+	if ro != nil {
+		route.Host = strings.ToLower(ro.HOST)
+	}
+
+	for i, rt := range r.Routes {
+		if rt.Pattern.String() == pattern && rt.Host == route.Host {
+			r.Routes[i] = route
+			return
+		}
+	}
 	r.Routes = append(r.Routes, route)
 
-	//Sort routes depending on the endoint lenght
+	//Sort routes depending on the endoint lenght, host bound routes first
 	sort.SliceStable(r.Routes, func(i, j int) bool {
-		return len(r.Routes[i].Pattern.String()) > len(r.Routes[j].Pattern.String())
+		li, lj := len(r.Routes[i].Pattern.String()), len(r.Routes[j].Pattern.String())
+		if li == lj {
+			return r.Routes[i].Host != "" && r.Routes[j].Host == ""
+		}
+		return li > lj
 	})
 }
 
+// Hosts - Hostnames routes are bound to
+func (r *Router) Hosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, rt := range r.Routes {
+		if rt.Host != "" && !seen[rt.Host] {
+			seen[rt.Host] = true
+			hosts = append(hosts, rt.Host)
+		}
+	}
+	return hosts
+}
+
+// match - First route of host matching path, with pattern captures
+func (r *Router) match(path string, host string) (*PatternRoute, []string) {
+	for _, rt := range r.Routes {
+		if rt.Host != "" && rt.Host != host {
+			continue
+		}
+		if matches := rt.Pattern.FindStringSubmatch(path); len(matches) > 0 {
+			return &rt, matches
+		}
+	}
+	return nil, nil
+}
+
 // ServerHTTP - Serve route from router
 func (r *Router) ServeHTTP(w http.ResponseWriter, re *http.Request) {
-	ctx := &Context{Request: re, ResponseWriter: w}
+	ctx := &Context{Request: re, ResponseWriter: w, ClientIP: ClientIP(re, r.TrustedProxies)}
+	if ip := net.ParseIP(remoteHost(re)); ip != nil {
+		ctx.trustedPeer = ContainsIP(r.TrustedProxies, ip)
+	}
 	var handler Handler
 
+	//VERIFIED CLIENT CERTIFICATE
+	ctx.ClientSubject = clientSubject(re)
+
+	host := strings.ToLower(re.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
 	//Search route
-	for _, rt := range r.Routes {
-		if matches := rt.Pattern.FindStringSubmatch(ctx.URL.Path); len(matches) > 0 {
+	rt, matches := r.match(ctx.URL.Path, host)
+
+	//MAINTENANCE, THEN REDIRECTS AND CANONICAL HOST BEFORE ANY MODULE
+	if mh := r.maintenanceHandler(ctx, rt); mh != nil {
+		handler = mh
+	} else if rd := r.redirectHandler(ctx, host); rd != nil {
+		handler = rd
+	} else if rt != nil {
+		ctx.RouteConfig = rt.RouteConfig
+		ctx.Route = rt.Route
+
+		if len(matches) > 1 {
+			ctx.Params = matches[1:]
+		}
 
-			ctx.RouteConfig = rt.RouteConfig
-			ctx.Route = rt.Route
+		handler = rt.Handler
 
-			if len(matches) > 1 {
-				ctx.Params = matches[1:]
+		//MODULE UNDER MAINTENANCE
+		if rt.RouteConfig != nil {
+			if m := rt.RouteConfig.Maintenance(); m != nil && !m.Bypass(ctx) {
+				handler = m.Handler(rt.RouteConfig)
 			}
-
-			handler = rt.Handler
-			break
 		}
 	}
 
@@ -118,6 +190,13 @@ type Context struct {
 	Params []string
 	*RouteConfig
 	*Route
+	ClientIP      string
+	ClientSubject string
+	User          string
+	headers       *HeaderRewriter
+	requestID     string
+	rewriter      *Rewriter
+	trustedPeer   bool
 }
 
 // Text - Send text to context writer
@@ -139,16 +218,156 @@ func (c *Context) HtmlText(code int, body string, data interface{}) {
 
 // Route - Route redirection
 type Route struct {
-	FROM string
-	TO   string
+	FROM             string
+	HOST             string
+	TO               string
+	HEADERS          HeaderRules
+	MIDDLEWARES      []MiddlewareConfig
+	REWRITE          []RewriteRule
+	REWRITE_COOKIES  bool
+	REWRITE_LOCATION bool
+	STREAMING        bool
 }
 
-// RouteConfig - Parameter to handle route redirection
+// RouteConfig - Parameter to handle route redirection, shared by all module routes
 type RouteConfig struct {
-	NAME    string
-	TYPES   string
-	BINDING ServerConfig
-	STATE   ModuleState
+	NAME          string
+	TYPES         string
+	BINDING       ServerConfig
+	STATE         ModuleState
+	ERRORS        ErrorPolicy
+	ERROR_PAGES   string
+	IDLE_TIMEOUT  time.Duration
+	MAINTENANCE   MaintenanceConfig
+	PRESERVE_HOST bool
+	PROXY         ProxyConfig
+	maintenance   *Maintenance
+	mux           sync.RWMutex
+	pages         *ErrorPages
+	proxy         *moduleProxy
+	upgrades      ConnTracker
+}
+
+// State - Current module state
+func (rc *RouteConfig) State() ModuleState {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.STATE
+}
+
+// SetState - Update module state seen by routes
+func (rc *RouteConfig) SetState(state ModuleState) {
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	rc.STATE = state
+}
+
+// Binding - Current module binding
+func (rc *RouteConfig) Binding() ServerConfig {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.BINDING
+}
+
+// PreserveHost - Client Host header is sent to module
+func (rc *RouteConfig) PreserveHost() bool {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.PRESERVE_HOST
+}
+
+// ErrorPolicy - Module statuses replaced by error pages
+func (rc *RouteConfig) ErrorPolicy() ErrorPolicy {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.ERRORS
+}
+
+// Maintenance - Module maintenance, nil when not under maintenance
+func (rc *RouteConfig) Maintenance() *Maintenance {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.maintenance
+}
+
+// Upstream - Module circuit state and retries, closed circuit before first request
+func (rc *RouteConfig) Upstream() UpstreamStats {
+	rc.mux.RLock()
+	mp := rc.proxy
+	rc.mux.RUnlock()
+	if mp == nil {
+		return UpstreamStats{CIRCUIT: CircuitClosed}
+	}
+	return mp.upstream.stats()
+}
+
+// errorPages - Module error page templates
+func (rc *RouteConfig) errorPages() *ErrorPages {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	return rc.pages
+}
+
+// Update - Replace binding and settings when module is hooked again, proxy being rebuilt when its upstream changes
+func (rc *RouteConfig) Update(from *RouteConfig) {
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+
+	if rc.proxy != nil && (rc.BINDING.BaseURL() != from.BINDING.BaseURL() || rc.BINDING.ADDRESS != from.BINDING.ADDRESS || rc.PROXY != from.PROXY || rc.TYPES != from.TYPES) {
+		rc.proxy.transport.CloseIdleConnections()
+		rc.proxy = nil
+	}
+
+	rc.NAME = from.NAME
+	rc.TYPES = from.TYPES
+	rc.BINDING = from.BINDING
+	rc.STATE = from.STATE
+	rc.ERRORS = from.ERRORS
+	if rc.pages == nil || rc.ERROR_PAGES != from.ERROR_PAGES {
+		rc.pages = nil
+		if from.ERROR_PAGES != "" {
+			pages, err := LoadErrorPages(from.ERROR_PAGES)
+			if err != nil {
+				log.Println("GO-WOXY Core - Error loading", from.NAME, "error pages :", err)
+			}
+			rc.pages = pages
+		}
+	}
+	rc.ERROR_PAGES = from.ERROR_PAGES
+	maintenance, err := NewMaintenance(from.MAINTENANCE)
+	if err != nil {
+		log.Println("GO-WOXY Core - Error reading", from.NAME, "maintenance config :", err)
+	}
+	rc.maintenance = maintenance
+	rc.MAINTENANCE = from.MAINTENANCE
+	rc.IDLE_TIMEOUT = from.IDLE_TIMEOUT
+	rc.PRESERVE_HOST = from.PRESERVE_HOST
+	rc.PROXY = from.PROXY
+	if rc.proxy == nil {
+		rc.proxy = newModuleProxy(rc.BINDING, rc.PROXY, strings.Contains(rc.TYPES, "grpc"))
+	}
+}
+
+// moduleProxy - Shared module proxy, built on first use when not hooked
+func (rc *RouteConfig) moduleProxy() *moduleProxy {
+	rc.mux.RLock()
+	mp := rc.proxy
+	rc.mux.RUnlock()
+	if mp != nil {
+		return mp
+	}
+
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	if rc.proxy == nil {
+		rc.proxy = newModuleProxy(rc.BINDING, rc.PROXY, strings.Contains(rc.TYPES, "grpc"))
+	}
+	return rc.proxy
+}
+
+// Upgrades - Upgraded and stream connections proxied to module
+func (rc *RouteConfig) Upgrades() *ConnTracker {
+	return &rc.upgrades
 }
 
 /*ServerConfig - Server configuration*/
@@ -162,6 +381,14 @@ type ServerConfig struct {
 	CERT_KEY string
 }
 
+// BaseURL - Server base url, unix sockets being reached through their transport
+func (sc *ServerConfig) BaseURL() string {
+	if sc.PROTOCOL == Unix {
+		return "http://localhost"
+	}
+	return sc.PROTOCOL + "://" + sc.ADDRESS + ":" + sc.PORT
+}
+
 // ModuleState - ModuleConfig State
 type ModuleState int
 
@@ -0,0 +1,148 @@
+package com
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Files issued by go-woxy internal CA, delivered to modules next to .secret
+const (
+	PeerCAFile   = ".ca.pem"
+	PeerCertFile = ".cert.pem"
+	PeerKeyFile  = ".key.pem"
+)
+
+var (
+	peerMux       sync.RWMutex
+	peerTLS       *PeerTLS
+	peerTransport *http.Transport
+)
+
+// PeerTLS - Certificate and internal CA used for mutual TLS between hub and modules
+type PeerTLS struct {
+	Certificate tls.Certificate
+	CAs         *x509.CertPool
+	roots       *x509.CertPool
+}
+
+// NewPeerTLS - Peer from PEM encoded certificate, key and internal CA
+func NewPeerTLS(certPEM []byte, keyPEM []byte, caPEM []byte) (*PeerTLS, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	cas := x509.NewCertPool()
+	if !cas.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificate found in internal CA")
+	}
+
+	//TRUST PUBLIC CERTIFICATES TOO, HUB MAY BE REACHED THROUGH ITS PUBLIC LISTENER
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	roots.AppendCertsFromPEM(caPEM)
+
+	return &PeerTLS{Certificate: cert, CAs: cas, roots: roots}, nil
+}
+
+// LoadPeerTLS - Load peer files from directory
+func LoadPeerTLS(dir string) (*PeerTLS, error) {
+	var files [3][]byte
+	for i, name := range []string{PeerCertFile, PeerKeyFile, PeerCAFile} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files[i] = b
+	}
+	return NewPeerTLS(files[0], files[1], files[2])
+}
+
+// ServerConfig - Server side, only peers with a certificate issued by internal CA are accepted
+func (p *PeerTLS) ServerConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{p.Certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    p.CAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientConfig - Client side, presenting peer certificate
+func (p *PeerTLS) ClientConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{p.Certificate},
+		RootCAs:      p.roots,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Verify - Check connection peer certificate was issued by internal CA
+func (p *PeerTLS) Verify(state *tls.ConnectionState) error {
+	_, err := VerifyClientCertificate(state, p.CAs)
+	return err
+}
+
+// VerifyClientCertificate - Chains of connection peer certificate up to roots, for client authentication
+func VerifyClientCertificate(state *tls.ConnectionState, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no peer certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	return state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// clientSubject - Subject of client certificate verified by handshake
+func clientSubject(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.String()
+}
+
+// SetPeerTLS - Use peer certificate for https requests and proxying to modules
+func SetPeerTLS(p *PeerTLS) {
+	peerMux.Lock()
+	defer peerMux.Unlock()
+	peerTLS = p
+	peerTransport = nil
+	if p != nil {
+		peerTransport = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     p.ClientConfig(),
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+	}
+}
+
+// GetPeerTLS - Peer certificate in use, nil without mutual TLS
+func GetPeerTLS() *PeerTLS {
+	peerMux.RLock()
+	defer peerMux.RUnlock()
+	return peerTLS
+}
+
+// PeerTransport - HTTP transport presenting peer certificate, nil without mutual TLS
+func PeerTransport() *http.Transport {
+	peerMux.RLock()
+	defer peerMux.RUnlock()
+	return peerTransport
+}
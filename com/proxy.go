@@ -0,0 +1,306 @@
+package com
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ProxyConfig - Connection pool to module, zero values keeping defaults
+type ProxyConfig struct {
+	BUFFER_SIZE             int
+	CIRCUIT                 CircuitConfig
+	DIAL_TIMEOUT            time.Duration
+	IDLE_CONN_TIMEOUT       time.Duration
+	KEEPALIVE               time.Duration
+	MAX_CONNS_PER_HOST      int
+	MAX_IDLE_CONNS          int
+	MAX_IDLE_CONNS_PER_HOST int
+	RESPONSE_HEADER_TIMEOUT time.Duration
+	RETRIES                 int
+	RETRY_BACKOFF           time.Duration
+	RETRY_BUDGET            float64
+	TIMEOUT                 time.Duration
+	TLS_HANDSHAKE_TIMEOUT   time.Duration
+}
+
+// DefaultProxyConfig - Pool settings used for unset values
+var DefaultProxyConfig = ProxyConfig{
+	BUFFER_SIZE:             32 * 1024,
+	CIRCUIT:                 CircuitConfig{COOLDOWN: 30 * time.Second, FAILURES: 5},
+	DIAL_TIMEOUT:            30 * time.Second,
+	IDLE_CONN_TIMEOUT:       90 * time.Second,
+	KEEPALIVE:               30 * time.Second,
+	MAX_IDLE_CONNS:          100,
+	MAX_IDLE_CONNS_PER_HOST: 100,
+	RETRIES:                 2,
+	RETRY_BACKOFF:           50 * time.Millisecond,
+	RETRY_BUDGET:            0.2,
+	TLS_HANDSHAKE_TIMEOUT:   10 * time.Second,
+}
+
+// withDefaults - Config with unset values taken from DefaultProxyConfig
+func (pc ProxyConfig) withDefaults() ProxyConfig {
+	d := DefaultProxyConfig
+	if pc.BUFFER_SIZE <= 0 {
+		pc.BUFFER_SIZE = d.BUFFER_SIZE
+	}
+	if pc.DIAL_TIMEOUT <= 0 {
+		pc.DIAL_TIMEOUT = d.DIAL_TIMEOUT
+	}
+	if pc.IDLE_CONN_TIMEOUT <= 0 {
+		pc.IDLE_CONN_TIMEOUT = d.IDLE_CONN_TIMEOUT
+	}
+	if pc.KEEPALIVE == 0 {
+		pc.KEEPALIVE = d.KEEPALIVE
+	}
+	if pc.MAX_CONNS_PER_HOST <= 0 {
+		pc.MAX_CONNS_PER_HOST = d.MAX_CONNS_PER_HOST
+	}
+	if pc.MAX_IDLE_CONNS <= 0 {
+		pc.MAX_IDLE_CONNS = d.MAX_IDLE_CONNS
+	}
+	if pc.MAX_IDLE_CONNS_PER_HOST <= 0 {
+		pc.MAX_IDLE_CONNS_PER_HOST = d.MAX_IDLE_CONNS_PER_HOST
+	}
+	if pc.RESPONSE_HEADER_TIMEOUT <= 0 {
+		pc.RESPONSE_HEADER_TIMEOUT = d.RESPONSE_HEADER_TIMEOUT
+	}
+	if pc.CIRCUIT.COOLDOWN <= 0 {
+		pc.CIRCUIT.COOLDOWN = d.CIRCUIT.COOLDOWN
+	}
+	if pc.CIRCUIT.FAILURES == 0 {
+		pc.CIRCUIT.FAILURES = d.CIRCUIT.FAILURES
+	}
+	if pc.RETRIES == 0 {
+		pc.RETRIES = d.RETRIES
+	}
+	if pc.RETRY_BACKOFF <= 0 {
+		pc.RETRY_BACKOFF = d.RETRY_BACKOFF
+	}
+	if pc.RETRY_BUDGET <= 0 {
+		pc.RETRY_BUDGET = d.RETRY_BUDGET
+	}
+	if pc.TIMEOUT <= 0 {
+		pc.TIMEOUT = d.TIMEOUT
+	}
+	if pc.TLS_HANDSHAKE_TIMEOUT <= 0 {
+		pc.TLS_HANDSHAKE_TIMEOUT = d.TLS_HANDSHAKE_TIMEOUT
+	}
+	return pc
+}
+
+// Transport - Module transport, unix sockets and peer certificate included
+func (pc ProxyConfig) Transport(binding ServerConfig) *http.Transport {
+	pc = pc.withDefaults()
+	dialer := &net.Dialer{Timeout: pc.DIAL_TIMEOUT, KeepAlive: pc.KEEPALIVE}
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		IdleConnTimeout:       pc.IDLE_CONN_TIMEOUT,
+		MaxConnsPerHost:       pc.MAX_CONNS_PER_HOST,
+		MaxIdleConns:          pc.MAX_IDLE_CONNS,
+		MaxIdleConnsPerHost:   pc.MAX_IDLE_CONNS_PER_HOST,
+		ResponseHeaderTimeout: pc.RESPONSE_HEADER_TIMEOUT,
+		TLSHandshakeTimeout:   pc.TLS_HANDSHAKE_TIMEOUT,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	switch binding.PROTOCOL {
+	case Unix:
+		path := binding.ADDRESS
+		t.Proxy = nil
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	case "https":
+		if peer := PeerTransport(); peer != nil {
+			t.TLSClientConfig = peer.TLSClientConfig.Clone()
+		}
+	}
+	return t
+}
+
+// bufferPools - Proxy copy buffers shared by modules with the same buffer size
+var bufferPools sync.Map
+
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func (bp *bufferPool) Get() []byte {
+	return *bp.pool.Get().(*[]byte)
+}
+
+func (bp *bufferPool) Put(b []byte) {
+	bp.pool.Put(&b)
+}
+
+// BufferPool - Shared pool of proxy copy buffers of given size
+func BufferPool(size int) httputil.BufferPool {
+	if bp, ok := bufferPools.Load(size); ok {
+		return bp.(*bufferPool)
+	}
+	bp := &bufferPool{pool: sync.Pool{New: func() interface{} {
+		b := make([]byte, size)
+		return &b
+	}}}
+	actual, _ := bufferPools.LoadOrStore(size, bp)
+	return actual.(*bufferPool)
+}
+
+// proxyKey - Request context key of the proxied request state
+type proxyKey struct{}
+
+// errProxyTimeout - Cause of requests cut by the module total timeout
+var errProxyTimeout = fmt.Errorf("module request timeout : %w", context.DeadlineExceeded)
+
+// proxyRequest - Per request data read by the module shared proxy, being itself the request context
+type proxyRequest struct {
+	context.Context
+	ctx     *Context
+	base    *url.URL
+	target  *url.URL
+	timeout *time.Timer
+	grpcWeb string
+}
+
+func (pr *proxyRequest) Value(key interface{}) interface{} {
+	if key == (proxyKey{}) {
+		return pr
+	}
+	return pr.Context.Value(key)
+}
+
+// moduleProxy - Long lived reverse proxies of a module, sharing their transport
+type moduleProxy struct {
+	base      *url.URL
+	transport *http.Transport
+	upstream  *upstreamTransport
+	timeout   time.Duration
+	proxy     *httputil.ReverseProxy
+	stream    *httputil.ReverseProxy
+	grpc      *httputil.ReverseProxy
+}
+
+// newModuleProxy - Build module proxies, target path and context being given with each request
+// gRPC modules get an HTTP/2 only transport
+func newModuleProxy(binding ServerConfig, pc ProxyConfig, grpc bool) *moduleProxy {
+	base, err := url.Parse(binding.BaseURL())
+	if err != nil {
+		log.Println("GO-WOXY Core - Error reading module url :", err)
+		base = &url.URL{}
+	}
+	pc = pc.withDefaults()
+	mp := &moduleProxy{base: base, timeout: pc.TIMEOUT}
+	if grpc {
+		mp.transport = pc.GRPCTransport(binding)
+	} else {
+		mp.transport = pc.Transport(binding)
+	}
+	mp.upstream = newUpstreamTransport(mp.transport, pc)
+	pool := BufferPool(pc.BUFFER_SIZE)
+
+	newProxy := func(flushInterval time.Duration) *httputil.ReverseProxy {
+		return &httputil.ReverseProxy{
+			Director:       proxyDirector,
+			Transport:      mp.upstream,
+			BufferPool:     pool,
+			FlushInterval:  flushInterval,
+			ErrorHandler:   ErrorHandler,
+			ModifyResponse: proxyModifyResponse,
+		}
+	}
+	mp.proxy = newProxy(0)
+	mp.stream = newProxy(-1)
+	if grpc {
+		mp.grpc = &httputil.ReverseProxy{
+			Director:       grpcDirector,
+			Transport:      mp.upstream,
+			BufferPool:     pool,
+			FlushInterval:  -1,
+			ErrorHandler:   grpcErrorHandler,
+			ModifyResponse: grpcModifyResponse,
+		}
+	}
+	return mp
+}
+
+// target - Module url of rewritten request url, u being completed in place
+func (mp *moduleProxy) target(u *url.URL) *url.URL {
+	u.Scheme = mp.base.Scheme
+	u.Host = mp.base.Host
+	return u
+}
+
+// serve - Proxy request to target, streaming routes flushing immediately
+func (mp *moduleProxy) serve(ctx *Context, target *url.URL, streaming bool) {
+	proxy := mp.proxy
+	if streaming {
+		proxy = mp.stream
+	}
+	//PROXY REQUEST IS THE REQUEST CONTEXT, SAVING A CONTEXT.WITHVALUE LAYER
+	mp.serveProxy(proxy, &proxyRequest{Context: ctx.Request.Context(), ctx: ctx, base: mp.base, target: target}, streaming)
+}
+
+// serveGRPC - Proxy gRPC call to target, gRPC-Web content type being given for translated calls
+func (mp *moduleProxy) serveGRPC(ctx *Context, target *url.URL, grpcWeb string) {
+	pr := &proxyRequest{Context: ctx.Request.Context(), ctx: ctx, base: mp.base, target: target, grpcWeb: grpcWeb}
+	mp.serveProxy(mp.grpc, pr, ctx.Route != nil && ctx.Route.STREAMING)
+}
+
+func (mp *moduleProxy) serveProxy(proxy *httputil.ReverseProxy, pr *proxyRequest, streaming bool) {
+	ctx := pr.ctx
+
+	//TOTAL TIMEOUT, STREAMS BEING LONG LIVED
+	//A TIMER RATHER THAN A DEADLINE, STOPPED WHEN THE MODULE ANSWERS WITH EVENTS
+	if mp.timeout > 0 && !streaming {
+		var cancel context.CancelCauseFunc
+		pr.Context, cancel = context.WithCancelCause(pr.Context)
+		pr.timeout = time.AfterFunc(mp.timeout, func() { cancel(errProxyTimeout) })
+		defer func() {
+			pr.timeout.Stop()
+			cancel(nil)
+		}()
+	}
+	proxy.ServeHTTP(ctx.ResponseWriter, ctx.Request.WithContext(pr))
+}
+
+func proxyDirector(req *http.Request) {
+	pr := req.Context().Value(proxyKey{}).(*proxyRequest)
+	req.URL.Scheme = pr.target.Scheme
+	req.Host = pr.target.Host
+	req.URL.Host = pr.target.Host
+	req.URL.Path = pr.target.Path
+	req.URL.RawPath = pr.target.RawPath
+	req.URL.RawQuery = pr.target.RawQuery
+
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
+
+	forwardHeaders(req, pr.ctx)
+}
+
+func proxyModifyResponse(res *http.Response) error {
+	pr := res.Request.Context().Value(proxyKey{}).(*proxyRequest)
+
+	//SERVER-SENT EVENTS ARE FLUSHED IMMEDIATELY BY THE PROXY, NEITHER WRITE NOR TOTAL TIMEOUT CUTTING THEM
+	if !pr.ctx.Route.STREAMING && IsEventStream(res.Header) {
+		disableWriteDeadline(pr.ctx.ResponseWriter)
+		if pr.timeout != nil {
+			pr.timeout.Stop()
+		}
+	}
+
+	rewriteLocations(res.Header, pr.ctx, pr.base)
+	rewriteResponseHeaders(res.Header, pr.ctx)
+	return interceptStatus(res, pr.ctx.RouteConfig)
+}
@@ -0,0 +1,80 @@
+package com
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+)
+
+// benchRouter - Router proxying /app to a module answering a small body
+func benchRouter(b *testing.B, handler HandlerFunc) *Router {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	b.Cleanup(backend.Close)
+
+	host, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	rc := &RouteConfig{NAME: "bench", TYPES: "reverse", STATE: Online, BINDING: ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}}
+	r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+	//REWRITER COMPILED AT HOOK TIME AS CORE DOES
+	route := &Route{FROM: "/app", TO: "/"}
+	rw, err := NewRewriter(route.RewriteRules()...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r.Handle("/app", rw.Handler(handler), rc, route)
+	return r
+}
+
+// testRouter - Router proxying route to a module served by handler
+func testRouter(t *testing.T, handler http.Handler, route Route) (*Router, *RouteConfig) {
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+
+	host, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	rc := &RouteConfig{NAME: "test", TYPES: "reverse", STATE: Online, BINDING: ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}}
+	r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+	r.Handle(route.FROM, ReverseProxy(), rc, &route)
+	return r, rc
+}
+
+func runProxyBenchmark(b *testing.B, r *Router) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/index", nil))
+			if w.Code != http.StatusOK {
+				b.Fatalf("status %d", w.Code)
+			}
+		}
+	})
+}
+
+func BenchmarkReverseProxy(b *testing.B) {
+	//PROXY BUILT FOR EACH REQUEST ON DEFAULT TRANSPORT, SAME HEADERS BEING FORWARDED
+	b.Run("per-request", func(b *testing.B) {
+		runProxyBenchmark(b, benchRouter(b, func(ctx *Context) {
+			binding := ctx.RouteConfig.Binding()
+			target, _ := url.Parse(binding.BaseURL() + "/index")
+			proxy := httputil.NewSingleHostReverseProxy(target)
+			proxy.Director = func(req *http.Request) {
+				req.URL.Scheme = target.Scheme
+				req.URL.Host = target.Host
+				req.Host = target.Host
+				req.URL.Path = target.Path
+				forwardHeaders(req, ctx)
+			}
+			proxy.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+		}))
+	})
+
+	b.Run("shared", func(b *testing.B) {
+		runProxyBenchmark(b, benchRouter(b, ReverseProxy()))
+	})
+}
@@ -0,0 +1,214 @@
+package com
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rateLimitOptions struct {
+	BURST    int
+	EXPIRE   time.Duration
+	KEY      string
+	MAX_KEYS int
+	RATE     float64
+	ZONE     string
+}
+
+var (
+	limitersMux sync.Mutex
+	limiters    = map[string]*RateLimiter{}
+)
+
+// rateLimitMiddleware - Token bucket rate limiting per client
+// Limiters declared with the same zone share their buckets
+func rateLimitMiddleware(options map[string]interface{}) (MiddlewareFunc, error) {
+	opts := rateLimitOptions{
+		EXPIRE:   10 * time.Minute,
+		KEY:      "ip",
+		MAX_KEYS: 10000,
+	}
+	if err := DecodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	if opts.RATE <= 0 {
+		return nil, errors.New("rate must be greater than 0")
+	}
+	if opts.BURST <= 0 {
+		opts.BURST = int(math.Ceil(opts.RATE))
+	}
+	if opts.KEY != "ip" && opts.KEY != "user" && !strings.HasPrefix(opts.KEY, "header:") {
+		return nil, errors.New("unknown key \"" + opts.KEY + "\" (supported : ip, user, header:<name>)")
+	}
+
+	limiter := NewRateLimiter(opts.ZONE, opts.RATE, opts.BURST, opts.KEY, opts.MAX_KEYS, opts.EXPIRE)
+	if opts.ZONE != "" {
+		limiter = registerLimiter(limiter)
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *Context) {
+			if ok, wait := limiter.Allow(limiter.keyOf(ctx)); !ok {
+				ctx.ResponseWriter.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				ctx.Text(http.StatusTooManyRequests, "GO-WOXY Core - Too many requests")
+				return
+			}
+			next.Handle(ctx)
+		})
+	}, nil
+}
+
+// registerLimiter - Share limiter state between chains of the same zone
+// An existing limiter is kept as long as its settings did not change
+func registerLimiter(l *RateLimiter) *RateLimiter {
+	limitersMux.Lock()
+	defer limitersMux.Unlock()
+	if existing, ok := limiters[l.zone]; ok && existing.sameSettings(l) {
+		return existing
+	}
+	limiters[l.zone] = l
+	return l
+}
+
+// RateLimiter - Token buckets indexed by key, bounded in size and expiring when idle
+type RateLimiter struct {
+	mux      sync.Mutex
+	zone     string
+	rate     float64
+	burst    float64
+	key      string
+	maxKeys  int
+	expire   time.Duration
+	buckets  map[string]*list.Element
+	lru      *list.List
+	allowed  uint64
+	rejected uint64
+}
+
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiterStats - Rate limiter metrics
+type RateLimiterStats struct {
+	ZONE     string
+	RATE     float64
+	BURST    int
+	KEY      string
+	KEYS     int
+	ALLOWED  uint64
+	REJECTED uint64
+}
+
+// NewRateLimiter - Create rate limiter allowing rate tokens per second up to burst for each key
+func NewRateLimiter(zone string, rate float64, burst int, key string, maxKeys int, expire time.Duration) *RateLimiter {
+	return &RateLimiter{
+		zone:    zone,
+		rate:    rate,
+		burst:   float64(burst),
+		key:     key,
+		maxKeys: maxKeys,
+		expire:  expire,
+		buckets: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Allow - Take a token for key, return the time to wait before the next one if none left
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	l.evict(now)
+
+	var b *tokenBucket
+	if e, ok := l.buckets[key]; ok {
+		b = e.Value.(*tokenBucket)
+		l.lru.MoveToFront(e)
+	} else {
+		b = &tokenBucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		l.rejected++
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	l.allowed++
+	return true, 0
+}
+
+// Stats - Get limiter metrics
+func (l *RateLimiter) Stats() RateLimiterStats {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return RateLimiterStats{
+		ZONE:     l.zone,
+		RATE:     l.rate,
+		BURST:    int(l.burst),
+		KEY:      l.key,
+		KEYS:     len(l.buckets),
+		ALLOWED:  l.allowed,
+		REJECTED: l.rejected,
+	}
+}
+
+// evict - Remove idle buckets and least recently used ones above maxKeys
+func (l *RateLimiter) evict(now time.Time) {
+	for e := l.lru.Back(); e != nil; e = l.lru.Back() {
+		b := e.Value.(*tokenBucket)
+		if (l.expire <= 0 || now.Sub(b.last) < l.expire) && (l.maxKeys <= 0 || l.lru.Len() < l.maxKeys) {
+			return
+		}
+		l.lru.Remove(e)
+		delete(l.buckets, b.key)
+	}
+}
+
+func (l *RateLimiter) keyOf(ctx *Context) string {
+	switch {
+	case l.key == "user" && ctx.User != "":
+		return "user:" + ctx.User
+	case strings.HasPrefix(l.key, "header:"):
+		if v := ctx.Request.Header.Get(strings.TrimPrefix(l.key, "header:")); v != "" {
+			return l.key + ":" + v
+		}
+	}
+	return "ip:" + ctx.ClientIP
+}
+
+func (l *RateLimiter) sameSettings(o *RateLimiter) bool {
+	return l.rate == o.rate && l.burst == o.burst && l.key == o.key && l.maxKeys == o.maxKeys && l.expire == o.expire
+}
+
+// RateLimitersStats - Get metrics of every zoned rate limiter
+func RateLimitersStats() []RateLimiterStats {
+	limitersMux.Lock()
+	all := make([]*RateLimiter, 0, len(limiters))
+	for _, l := range limiters {
+		all = append(all, l)
+	}
+	limitersMux.Unlock()
+
+	stats := make([]RateLimiterStats, 0, len(all))
+	for _, l := range all {
+		stats = append(stats, l.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ZONE < stats[j].ZONE
+	})
+	return stats
+}
@@ -0,0 +1,113 @@
+package com
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBucket(t *testing.T) {
+	l := NewRateLimiter("", 1, 3, "ip", 0, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d refused within burst", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request allowed after burst")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want within (0, 1s]", wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("other key refused")
+	}
+
+	//REFILL AT RATE
+	l = NewRateLimiter("", 100, 1, "ip", 0, 0)
+	l.Allow("a")
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("request allowed with empty bucket")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("bucket not refilled")
+	}
+
+	if s := l.Stats(); s.ALLOWED != 2 || s.REJECTED != 1 {
+		t.Errorf("stats = %+v, want 2 allowed and 1 rejected", s)
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	l := NewRateLimiter("", 1, 1, "ip", 2, 0)
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("a")
+	l.Allow("c")
+
+	//LEAST RECENTLY USED KEY DROPPED, ITS BUCKET STARTING FULL AGAIN
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("least recently used key kept")
+	}
+	if _, ok := l.buckets["a"]; !ok {
+		t.Error("recently used key evicted")
+	}
+	if n := l.Stats().KEYS; n != 2 {
+		t.Errorf("keys = %d, want 2", n)
+	}
+
+	l = NewRateLimiter("", 1, 1, "ip", 0, 10*time.Millisecond)
+	l.Allow("a")
+	time.Sleep(20 * time.Millisecond)
+	l.Allow("b")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("idle key not expired")
+	}
+}
+
+func TestRateLimitZones(t *testing.T) {
+	configs := []MiddlewareConfig{
+		{NAME: "rate-limit", OPTIONS: map[string]interface{}{"rate": 1}},
+		{NAME: "cors"},
+		{NAME: "rate-limit", OPTIONS: map[string]interface{}{"rate": 2}},
+		{NAME: "rate-limit", OPTIONS: map[string]interface{}{"rate": 3, "zone": "shared"}},
+	}
+	zonedConfigs := WithZone(configs, "mod")
+	want := []interface{}{"mod#0", nil, "mod#2", "shared"}
+	for i, cfg := range zonedConfigs {
+		if got := cfg.OPTIONS["zone"]; got != want[i] {
+			t.Errorf("entry %d zone = %v, want %v", i, got, want[i])
+		}
+	}
+	if _, ok := configs[0].OPTIONS["zone"]; ok {
+		t.Error("configuration options modified")
+	}
+
+	//SAME ENTRY SHARES ITS LIMITER ACROSS CHAINS, OTHERS DON'T
+	first, err := NewChain(zonedConfigs[0], zonedConfigs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewChain(zonedConfigs[0], zonedConfigs[2]); err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("chain length = %d, want 2", len(first))
+	}
+	rates := map[string]float64{}
+	for _, s := range RateLimitersStats() {
+		rates[s.ZONE] = s.RATE
+	}
+	if rates["mod#0"] != 1 || rates["mod#2"] != 2 {
+		t.Errorf("zones = %v, want mod#0 at rate 1 and mod#2 at rate 2", rates)
+	}
+
+	a := registerLimiter(NewRateLimiter("z", 1, 1, "ip", 0, 0))
+	if b := registerLimiter(NewRateLimiter("z", 1, 1, "ip", 0, 0)); a != b {
+		t.Error("limiter of same zone and settings not shared")
+	}
+	if c := registerLimiter(NewRateLimiter("z", 2, 1, "ip", 0, 0)); a == c {
+		t.Error("limiter kept after settings change")
+	}
+}
@@ -0,0 +1,212 @@
+package com
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedirectRule - Route answered with a redirect, without module
+type RedirectRule struct {
+	FROM           string
+	HOST           string
+	PRESERVE_QUERY bool
+	STATUS         int
+	TO             string
+}
+
+// CanonicalConfig - Host and path policy enforced with redirects on GET and HEAD requests
+type CanonicalConfig struct {
+	HOST           string
+	STATUS         int
+	TRAILING_SLASH string
+	WWW            string
+}
+
+// redirect - Compiled redirect rule
+type redirect struct {
+	pattern   *regexp.Regexp
+	host      string
+	to        string
+	status    int
+	keepQuery bool
+}
+
+// redirectStatus - Rule status, 301 by default
+func redirectStatus(status int) (int, error) {
+	switch status {
+	case 0:
+		return http.StatusMovedPermanently, nil
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return status, nil
+	}
+	return 0, errors.New("redirect status " + strconv.Itoa(status) + " not in 301, 302, 307, 308")
+}
+
+// Redirect - Add redirect rule, checked before module routes
+func (r *Router) Redirect(rule RedirectRule) error {
+	if rule.FROM == "" || rule.TO == "" {
+		return errors.New("redirect needs from and to")
+	}
+	re, err := regexp.Compile(rule.FROM)
+	if err != nil {
+		return err
+	}
+	status, err := redirectStatus(rule.STATUS)
+	if err != nil {
+		return err
+	}
+	r.redirects = append(r.redirects, redirect{pattern: re, host: strings.ToLower(rule.HOST), to: rule.TO, status: status, keepQuery: rule.PRESERVE_QUERY})
+	return nil
+}
+
+// SetCanonical - Enforce canonical host and trailing slash policy, nothing being enforced by default
+func (r *Router) SetCanonical(cc CanonicalConfig) error {
+	if cc.HOST == "" && cc.TRAILING_SLASH == "" && cc.WWW == "" {
+		r.canonical = nil
+		return nil
+	}
+	switch cc.WWW {
+	case "", "add", "remove":
+	default:
+		return errors.New("canonical www must be add or remove")
+	}
+	switch cc.TRAILING_SLASH {
+	case "", "add", "remove":
+	default:
+		return errors.New("canonical trailing_slash must be add or remove")
+	}
+	if cc.HOST != "" && cc.WWW != "" {
+		return errors.New("canonical host and www can't be both set")
+	}
+	status, err := redirectStatus(cc.STATUS)
+	if err != nil {
+		return err
+	}
+	cc.STATUS = status
+	cc.HOST = strings.ToLower(cc.HOST)
+	r.canonical = &cc
+	return nil
+}
+
+// redirectHandler - Handler of first redirect matching request, nil without one
+func (r *Router) redirectHandler(ctx *Context, host string) HandlerFunc {
+	if r.canonical != nil {
+		if target := r.canonical.target(ctx, host, r.boundHost(host)); target != "" {
+			return redirectTo(target, r.canonical.STATUS)
+		}
+	}
+
+	for _, rd := range r.redirects {
+		if rd.host != "" && rd.host != host {
+			continue
+		}
+		matches := rd.pattern.FindStringSubmatchIndex(ctx.URL.Path)
+		if matches == nil {
+			continue
+		}
+		target := sameHostPath(string(rd.pattern.ExpandString(nil, rd.to, ctx.URL.Path, matches)))
+		if rd.keepQuery && ctx.URL.RawQuery != "" {
+			sep := "?"
+			if strings.Contains(target, "?") {
+				sep = "&"
+			}
+			target += sep + ctx.URL.RawQuery
+		}
+		return redirectTo(target, rd.status)
+	}
+	return nil
+}
+
+// sameHostPath - Path target with leading slashes collapsed, never a protocol relative url pointing to another host
+func sameHostPath(target string) string {
+	if strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/" + strings.TrimLeft(target, "/\\")
+	}
+	return target
+}
+
+func redirectTo(target string, status int) HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		http.Redirect(ctx.ResponseWriter, ctx.Request, target, status)
+	})
+}
+
+// boundHost - Host has its own routes, kept out of canonical host redirects
+func (r *Router) boundHost(host string) bool {
+	for _, rt := range r.Routes {
+		if rt.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// target - Canonical url of request, empty when already canonical
+func (cc *CanonicalConfig) target(ctx *Context, host string, bound bool) string {
+	if ctx.Method != http.MethodGet && ctx.Method != http.MethodHead {
+		return ""
+	}
+
+	canonicalHost := host
+	if host != "" && net.ParseIP(host) == nil && host != "localhost" && !bound {
+		switch {
+		case cc.HOST != "":
+			canonicalHost = cc.HOST
+		case cc.WWW == "add" && !strings.HasPrefix(host, "www."):
+			canonicalHost = "www." + host
+		case cc.WWW == "remove":
+			canonicalHost = strings.TrimPrefix(host, "www.")
+		}
+	}
+
+	p := ctx.URL.EscapedPath()
+	canonicalPath := p
+	switch cc.TRAILING_SLASH {
+	case "add":
+		//FILES KEEP THEIR NAME
+		if !strings.HasSuffix(p, "/") && !strings.Contains(path.Base(p), ".") {
+			canonicalPath = p + "/"
+		}
+	case "remove":
+		if p != "/" {
+			canonicalPath = strings.TrimRight(p, "/")
+			if canonicalPath == "" {
+				canonicalPath = "/"
+			}
+		}
+	}
+
+	canonicalPath = sameHostPath(canonicalPath)
+
+	if canonicalHost == host && canonicalPath == p {
+		return ""
+	}
+
+	target := canonicalPath
+	if canonicalHost != host {
+		if _, port, err := net.SplitHostPort(ctx.Request.Host); err == nil {
+			canonicalHost = net.JoinHostPort(canonicalHost, port)
+		}
+		target = requestScheme(ctx) + "://" + canonicalHost + canonicalPath
+	}
+	if ctx.URL.RawQuery != "" {
+		target += "?" + ctx.URL.RawQuery
+	}
+	return target
+}
+
+// requestScheme - Client side scheme, given by trusted proxies
+func requestScheme(ctx *Context) string {
+	if proto := ctx.Request.Header.Get(HeaderForwardedProto); ctx.trustedPeer && (proto == "http" || proto == "https") {
+		return proto
+	}
+	if ctx.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
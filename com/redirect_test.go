@@ -0,0 +1,121 @@
+package com
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// redirectRouter - Router with a catch-all route, answering 200 when nothing redirects
+func redirectRouter() *Router {
+	r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+	r.Handle("/", HandlerFunc(func(ctx *Context) { ctx.Text(http.StatusOK, "ok") }), nil, &Route{FROM: "/"})
+	return r
+}
+
+func TestRedirectRules(t *testing.T) {
+	r := redirectRouter()
+	rules := []RedirectRule{
+		{FROM: "^/old/(.*)$", TO: "/$1"},
+		{FROM: "^/docs$", TO: "https://docs.example.com/", STATUS: http.StatusFound},
+		{FROM: "^/search$", TO: "/find?src=old", PRESERVE_QUERY: true, STATUS: http.StatusPermanentRedirect},
+		{FROM: "^/keep$", TO: "/kept", PRESERVE_QUERY: true},
+		{FROM: "^/drop$", TO: "/dropped"},
+		{FROM: "^/hosted$", TO: "/elsewhere", HOST: "Other.example.com"},
+	}
+	for _, rule := range rules {
+		if err := r.Redirect(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		code     int
+		location string
+	}{
+		{"capture", "http://example.com/old/page", http.StatusMovedPermanently, "/page"},
+		{"double slash capture", "http://example.com/old//evil.com", http.StatusMovedPermanently, "/evil.com"},
+		{"backslash capture", "http://example.com/old/%5Cevil.com", http.StatusMovedPermanently, "/evil.com"},
+		{"absolute target", "http://example.com/docs", http.StatusFound, "https://docs.example.com/"},
+		{"query appended", "http://example.com/search?q=a", http.StatusPermanentRedirect, "/find?src=old&q=a"},
+		{"query preserved", "http://example.com/keep?q=a", http.StatusMovedPermanently, "/kept?q=a"},
+		{"query dropped", "http://example.com/drop?q=a", http.StatusMovedPermanently, "/dropped"},
+		{"other host", "http://example.com/hosted", http.StatusOK, ""},
+		{"host rule", "http://other.example.com/hosted", http.StatusMovedPermanently, "/elsewhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Header().Get("Location"), tt.code, tt.location)
+			}
+		})
+	}
+}
+
+func TestRedirectValidation(t *testing.T) {
+	r := redirectRouter()
+	for _, rule := range []RedirectRule{
+		{FROM: "^/a$"},
+		{TO: "/b"},
+		{FROM: "(", TO: "/b"},
+		{FROM: "^/a$", TO: "/b", STATUS: http.StatusOK},
+		{FROM: "^/a$", TO: "/b", STATUS: http.StatusNotModified},
+	} {
+		if err := r.Redirect(rule); err == nil {
+			t.Errorf("%+v accepted", rule)
+		}
+	}
+
+	for _, cc := range []CanonicalConfig{
+		{WWW: "always"},
+		{TRAILING_SLASH: "keep"},
+		{HOST: "example.com", WWW: "add"},
+		{WWW: "add", STATUS: http.StatusSeeOther},
+	} {
+		if err := r.SetCanonical(cc); err == nil {
+			t.Errorf("%+v accepted", cc)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name     string
+		cc       CanonicalConfig
+		method   string
+		url      string
+		code     int
+		location string
+	}{
+		{"www add", CanonicalConfig{WWW: "add"}, "GET", "http://example.com/a?q=1", http.StatusMovedPermanently, "http://www.example.com/a?q=1"},
+		{"www add kept", CanonicalConfig{WWW: "add"}, "GET", "http://www.example.com/a", http.StatusOK, ""},
+		{"www add port kept", CanonicalConfig{WWW: "add"}, "GET", "http://example.com:8080/a", http.StatusMovedPermanently, "http://www.example.com:8080/a"},
+		{"www add ip untouched", CanonicalConfig{WWW: "add"}, "GET", "http://127.0.0.1/a", http.StatusOK, ""},
+		{"www remove", CanonicalConfig{WWW: "remove", STATUS: http.StatusPermanentRedirect}, "GET", "http://www.example.com/a", http.StatusPermanentRedirect, "http://example.com/a"},
+		{"host", CanonicalConfig{HOST: "Example.com"}, "GET", "http://alias.example.net/a", http.StatusMovedPermanently, "http://example.com/a"},
+		{"post untouched", CanonicalConfig{WWW: "add"}, "POST", "http://example.com/a", http.StatusOK, ""},
+		{"slash add", CanonicalConfig{TRAILING_SLASH: "add"}, "GET", "http://example.com/a", http.StatusMovedPermanently, "/a/"},
+		{"slash add file", CanonicalConfig{TRAILING_SLASH: "add"}, "GET", "http://example.com/a.css", http.StatusOK, ""},
+		{"slash remove", CanonicalConfig{TRAILING_SLASH: "remove"}, "HEAD", "http://example.com/a//?q=1", http.StatusMovedPermanently, "/a?q=1"},
+		{"slash remove root", CanonicalConfig{TRAILING_SLASH: "remove"}, "GET", "http://example.com/", http.StatusOK, ""},
+		{"slash remove double slash path", CanonicalConfig{TRAILING_SLASH: "remove"}, "GET", "http://example.com//evil.com/", http.StatusMovedPermanently, "/evil.com"},
+		{"slash add double slash path", CanonicalConfig{TRAILING_SLASH: "add"}, "GET", "http://example.com//evil", http.StatusMovedPermanently, "/evil/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := redirectRouter()
+			if err := r.SetCanonical(tt.cc); err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+			if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Header().Get("Location"), tt.code, tt.location)
+			}
+		})
+	}
+}
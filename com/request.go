@@ -10,6 +10,13 @@ import (
 
 var defaultPath = "/connect"
 
+// Environment variables given to modules started by go-woxy
+const (
+	EnvHubAddress  = "GOWOXY_HUB_ADDRESS"
+	EnvHubPort     = "GOWOXY_HUB_PORT"
+	EnvHubProtocol = "GOWOXY_HUB_PROTOCOL"
+)
+
 // IP Address
 type IP string
 
@@ -0,0 +1,173 @@
+package com
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule - Path rewrite applied before proxying, one action per rule
+type RewriteRule struct {
+	ADD_PREFIX   string
+	REGEX        string
+	REPLACEMENT  string
+	STRIP_PREFIX string
+}
+
+// rewriteStep - Compiled rewrite rule
+type rewriteStep struct {
+	strip       string
+	add         string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Rewriter - Compiled route rewrite rules, working on escaped paths
+type Rewriter struct {
+	steps []rewriteStep
+
+	//PREFIXES OF RULES THAT CAN BE REVERSED
+	public     string
+	upstream   string
+	reversible bool
+}
+
+// RewriteRules - Route rules, FROM being replaced by TO without explicit ones
+func (r *Route) RewriteRules() []RewriteRule {
+	if len(r.REWRITE) > 0 {
+		return r.REWRITE
+	}
+	if r.FROM == r.TO {
+		return nil
+	}
+
+	var rules []RewriteRule
+	if r.FROM != "/" {
+		rules = append(rules, RewriteRule{STRIP_PREFIX: r.FROM})
+	}
+	if r.TO != "/" {
+		rules = append(rules, RewriteRule{ADD_PREFIX: r.TO})
+	}
+	return rules
+}
+
+// NewRewriter - Compile rewrite rules in order
+func NewRewriter(rules ...RewriteRule) (*Rewriter, error) {
+	rw := &Rewriter{}
+	for _, rule := range rules {
+		var step rewriteStep
+		switch {
+		case rule.STRIP_PREFIX != "" && rule.ADD_PREFIX == "" && rule.REGEX == "":
+			step.strip = "/" + strings.Trim(rule.STRIP_PREFIX, "/")
+		case rule.ADD_PREFIX != "" && rule.STRIP_PREFIX == "" && rule.REGEX == "":
+			step.add = "/" + strings.Trim(rule.ADD_PREFIX, "/")
+		case rule.REGEX != "" && rule.STRIP_PREFIX == "" && rule.ADD_PREFIX == "":
+			re, err := regexp.Compile(rule.REGEX)
+			if err != nil {
+				return nil, err
+			}
+			step.re = re
+			step.replacement = rule.REPLACEMENT
+		default:
+			return nil, errors.New("rewrite rule needs exactly one of strip_prefix, add_prefix or regex")
+		}
+		rw.steps = append(rw.steps, step)
+	}
+
+	//ONE STRIP AND ONE ADD AT MOST, REGEX REPLACEMENTS CAN'T BE REVERSED
+	strips, adds := 0, 0
+	rw.reversible = true
+	for _, step := range rw.steps {
+		switch {
+		case step.re != nil:
+			rw.reversible = false
+		case step.strip != "":
+			strips++
+			rw.public = strings.TrimSuffix(step.strip, "/")
+		case step.add != "":
+			adds++
+			rw.upstream = strings.TrimSuffix(step.add, "/")
+		}
+	}
+	rw.reversible = rw.reversible && strips <= 1 && adds <= 1 && rw.public != rw.upstream
+	return rw, nil
+}
+
+// Reverse - Public path of a module path, false when rules can't map it back
+func (rw *Rewriter) Reverse(path string) (string, bool) {
+	if !rw.reversible || !strings.HasPrefix(path, "/") {
+		return path, false
+	}
+	rest := path
+	if rw.upstream != "" {
+		if path != rw.upstream && !strings.HasPrefix(path, rw.upstream+"/") {
+			return path, false
+		}
+		rest = path[len(rw.upstream):]
+	}
+	if rest == "" && rw.public == "" {
+		rest = "/"
+	}
+	return rw.public + rest, true
+}
+
+// Handler - Make rewriter available to module proxy
+func (rw *Rewriter) Handler(next HandlerFunc) HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		ctx.rewriter = rw
+		next(ctx)
+	})
+}
+
+// Rewrite - Apply rules on escaped path, result always starting with a slash
+func (rw *Rewriter) Rewrite(path string) string {
+	for _, step := range rw.steps {
+		switch {
+		case step.strip != "":
+			//WHOLE SEGMENTS ONLY, /app NOT MATCHING /application
+			if step.strip == "/" {
+				break
+			} else if path == step.strip {
+				path = "/"
+			} else if strings.HasPrefix(path, step.strip+"/") {
+				path = path[len(step.strip):]
+			}
+		case step.add != "":
+			if step.add != "/" {
+				path = step.add + path
+			}
+		case step.re != nil:
+			path = step.re.ReplaceAllString(path, step.replacement)
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	return path
+}
+
+// RewriteURL - Module url path and query of request url
+func (rw *Rewriter) RewriteURL(u *url.URL) *url.URL {
+	escaped := rw.Rewrite(u.EscapedPath())
+	out := &url.URL{RawQuery: u.RawQuery}
+	if path, err := url.PathUnescape(escaped); err == nil {
+		out.Path = path
+		out.RawPath = escaped
+	} else {
+		out.Path = escaped
+	}
+	return out
+}
+
+// routeRewriter - Rewriter compiled at hook time, built from route otherwise
+func (ctx *Context) routeRewriter() *Rewriter {
+	if ctx.rewriter != nil {
+		return ctx.rewriter
+	}
+	rw, err := NewRewriter(ctx.Route.RewriteRules()...)
+	if err != nil {
+		return &Rewriter{}
+	}
+	return rw
+}
@@ -0,0 +1,230 @@
+package com
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []RewriteRule
+		path  string
+		want  string
+	}{
+		{"no rules", nil, "/a/b", "/a/b"},
+		{"strip prefix", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/app/index", "/index"},
+		{"strip whole path", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/app", "/"},
+		{"strip trailing slash prefix", []RewriteRule{{STRIP_PREFIX: "/app/"}}, "/app/index", "/index"},
+		{"strip whole segments only", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/application", "/application"},
+		{"strip prefix only", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/api/app/index", "/api/app/index"},
+		{"add prefix", []RewriteRule{{ADD_PREFIX: "/v1"}}, "/users", "/v1/users"},
+		{"add trailing slash prefix", []RewriteRule{{ADD_PREFIX: "/v1/"}}, "/users", "/v1/users"},
+		{"add prefix already in path", []RewriteRule{{ADD_PREFIX: "/v1"}}, "/docs/v1", "/v1/docs/v1"},
+		{"strip then add", []RewriteRule{{STRIP_PREFIX: "/app"}, {ADD_PREFIX: "/api"}}, "/app/users", "/api/users"},
+		{"regex captures", []RewriteRule{{REGEX: `^/items/([0-9]+)/(\w+)$`, REPLACEMENT: "/v2/$2/$1"}}, "/items/42/detail", "/v2/detail/42"},
+		{"regex named capture", []RewriteRule{{REGEX: `^/u/(?P<id>[^/]+)`, REPLACEMENT: "/users/${id}"}}, "/u/bob/home", "/users/bob/home"},
+		{"regex no match", []RewriteRule{{REGEX: `^/items/([0-9]+)$`, REPLACEMENT: "/$1"}}, "/items/abc", "/items/abc"},
+		{"regex keeps leading slash", []RewriteRule{{REGEX: `^/old/`, REPLACEMENT: ""}}, "/old/page", "/page"},
+		{"encoded path kept", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/app/a%2Fb/c%20d", "/a%2Fb/c%20d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := NewRewriter(tt.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rw.Rewrite(tt.path); got != tt.want {
+				t.Errorf("Rewrite(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteRewriteRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		path  string
+		want  string
+	}{
+		{"same from and to", Route{FROM: "/app", TO: "/app"}, "/app/index", "/app/index"},
+		{"from stripped", Route{FROM: "/app", TO: "/"}, "/app/index", "/index"},
+		{"from trailing slash", Route{FROM: "/app/", TO: "/"}, "/app/index", "/index"},
+		{"to added", Route{FROM: "/", TO: "/api"}, "/users", "/api/users"},
+		{"from replaced by to", Route{FROM: "/app", TO: "/api"}, "/app/users", "/api/users"},
+		{"from not stripped inside path", Route{FROM: "/app", TO: "/"}, "/static/app/logo.png", "/static/app/logo.png"},
+		{"to added when inside path", Route{FROM: "/app", TO: "/api"}, "/app/docs/api", "/api/docs/api"},
+		{"explicit rules override from and to", Route{FROM: "/app", TO: "/api", REWRITE: []RewriteRule{{ADD_PREFIX: "/v2"}}}, "/app/users", "/v2/app/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := NewRewriter(tt.route.RewriteRules()...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rw.Rewrite(tt.path); got != tt.want {
+				t.Errorf("Rewrite(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRewriterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule RewriteRule
+	}{
+		{"empty rule", RewriteRule{}},
+		{"two actions", RewriteRule{STRIP_PREFIX: "/a", ADD_PREFIX: "/b"}},
+		{"invalid regex", RewriteRule{REGEX: "("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRewriter(tt.rule); err == nil {
+				t.Errorf("NewRewriter(%+v) succeeded, want error", tt.rule)
+			}
+		})
+	}
+}
+
+func TestReverseProxyRewrite(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI)
+	}))
+	defer backend.Close()
+	host, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+
+	tests := []struct {
+		name   string
+		route  Route
+		target string
+		want   string
+	}{
+		{"query kept", Route{FROM: "/app", TO: "/"}, "/app/search?q=a+b&page=2", "/search?q=a+b&page=2"},
+		{"encoded path kept", Route{FROM: "/app", TO: "/"}, "/app/files/a%2Fb?x=1", "/files/a%2Fb?x=1"},
+		{"to added", Route{FROM: "/app", TO: "/api"}, "/app/users?id=3", "/api/users?id=3"},
+		{"regex rule", Route{FROM: "/app", REWRITE: []RewriteRule{{REGEX: `^/app/items/([0-9]+)$`, REPLACEMENT: "/v2/items/$1"}}}, "/app/items/7?full=1", "/v2/items/7?full=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := tt.route
+			rc := &RouteConfig{NAME: "rewrite", TYPES: "reverse", STATE: Online, BINDING: ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}}
+			r := NewRouter(func(ctx *Context) { ctx.Text(http.StatusNotFound, "not found") })
+			r.Handle(route.FROM, ReverseProxy(), rc, &route)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d", w.Code)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("module got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriterReverse(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []RewriteRule
+		path  string
+		want  string
+		ok    bool
+	}{
+		{"no rules", nil, "/a", "/a", false},
+		{"strip prefix", []RewriteRule{{STRIP_PREFIX: "/app"}}, "/login", "/app/login", true},
+		{"strip prefix root", []RewriteRule{{STRIP_PREFIX: "/app/"}}, "/", "/app/", true},
+		{"add prefix", []RewriteRule{{ADD_PREFIX: "/v1"}}, "/v1/users", "/users", true},
+		{"add prefix itself", []RewriteRule{{ADD_PREFIX: "/v1"}}, "/v1", "/", true},
+		{"add prefix outside", []RewriteRule{{ADD_PREFIX: "/v1"}}, "/v10/users", "/v10/users", false},
+		{"strip then add", []RewriteRule{{STRIP_PREFIX: "/app"}, {ADD_PREFIX: "/api"}}, "/api/users", "/app/users", true},
+		{"strip then add prefix itself", []RewriteRule{{STRIP_PREFIX: "/app"}, {ADD_PREFIX: "/api"}}, "/api", "/app", true},
+		{"strip then add outside", []RewriteRule{{STRIP_PREFIX: "/app"}, {ADD_PREFIX: "/api"}}, "/static/logo.png", "/static/logo.png", false},
+		{"regex", []RewriteRule{{REGEX: "^/a/(.*)$", REPLACEMENT: "/b/$1"}}, "/b/x", "/b/x", false},
+		{"two strips", []RewriteRule{{STRIP_PREFIX: "/a"}, {STRIP_PREFIX: "/b"}}, "/x", "/x", false},
+		{"same prefixes", []RewriteRule{{STRIP_PREFIX: "/app"}, {ADD_PREFIX: "/app"}}, "/app/x", "/app/x", false},
+		{"relative path", []RewriteRule{{STRIP_PREFIX: "/app"}}, "login", "login", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := NewRewriter(tt.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := rw.Reverse(tt.path); got != tt.want || ok != tt.ok {
+				t.Errorf("Reverse(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	base, _ := url.Parse("http://127.0.0.1:8081")
+	ctx := &Context{Request: httptest.NewRequest(http.MethodGet, "http://public.example.com/app/account", nil)}
+	prefix, _ := NewRewriter(RewriteRule{STRIP_PREFIX: "/app"})
+	regex, _ := NewRewriter(RewriteRule{REGEX: "^/app/(.*)$", REPLACEMENT: "/$1"})
+
+	tests := []struct {
+		name string
+		rw   *Rewriter
+		raw  string
+		want string
+	}{
+		{"path", prefix, "/login?next=%2F", "/app/login?next=%2F"},
+		{"encoded path", prefix, "/files/a%2Fb", "/app/files/a%2Fb"},
+		{"module host", prefix, "http://127.0.0.1:8081/login", "http://public.example.com/app/login"},
+		{"requested host", prefix, "http://public.example.com/login", "http://public.example.com/app/login"},
+		{"other host", prefix, "https://auth.example.org/login", "https://auth.example.org/login"},
+		{"relative path", prefix, "login", "login"},
+		{"other scheme", prefix, "mailto:admin@example.com", "mailto:admin@example.com"},
+		{"irreversible rules keep path", regex, "http://127.0.0.1:8081/login", "http://public.example.com/login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := publicURL(tt.raw, ctx, tt.rw, base); got != tt.want {
+				t.Errorf("publicURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	if got := publicRefresh("5; url=/next", ctx, prefix, base); got != "5; url=/app/next" {
+		t.Errorf("publicRefresh = %q", got)
+	}
+}
+
+func TestPublicCookie(t *testing.T) {
+	base, _ := url.Parse("http://127.0.0.1:8081")
+	prefix, _ := NewRewriter(RewriteRule{STRIP_PREFIX: "/app"})
+	replaced, _ := NewRewriter(RewriteRule{STRIP_PREFIX: "/app"}, RewriteRule{ADD_PREFIX: "/api"})
+	regex, _ := NewRewriter(RewriteRule{REGEX: "^/app/(.*)$", REPLACEMENT: "/$1"})
+
+	tests := []struct {
+		name   string
+		rw     *Rewriter
+		cookie string
+		want   string
+	}{
+		{"root path", prefix, "sid=1; Path=/", "sid=1; Path=/app"},
+		{"sub path", prefix, "sid=1; Path=/account; HttpOnly", "sid=1; Path=/app/account; HttpOnly"},
+		{"no path", prefix, "sid=1; Secure", "sid=1; Secure"},
+		{"module prefix root", replaced, "sid=1; Path=/api/", "sid=1; Path=/app"},
+		{"path outside module prefix", replaced, "sid=1; Path=/static", "sid=1; Path=/static"},
+		{"module domain dropped", prefix, "sid=1; Path=/; Domain=127.0.0.1; HttpOnly", "sid=1; Path=/app; HttpOnly"},
+		{"module domain with dot dropped", prefix, "sid=1; Domain=.127.0.0.1", "sid=1"},
+		{"other domain kept", prefix, "sid=1; Domain=example.org", "sid=1; Domain=example.org"},
+		{"irreversible rules keep path", regex, "sid=1; Path=/", "sid=1; Path=/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := publicCookie(tt.cookie, tt.rw, base); got != tt.want {
+				t.Errorf("publicCookie(%q) = %q, want %q", tt.cookie, got, tt.want)
+			}
+		})
+	}
+}
@@ -26,9 +26,18 @@ func SendRequest(s Server, r Request, loging bool) (string, error) {
 	}
 
 	var url = string(s.Protocol) + "://" + string(s.IP) + ":" + string(s.Port) + customPath
+	client := http.DefaultClient
+
+	//DIAL UNIX SOCKET WHATEVER THE URL HOST
+	if s.Protocol == Unix {
+		url = "http://localhost" + customPath
+		client = &http.Client{Transport: UnixTransport(string(s.IP))}
+	} else if t := PeerTransport(); t != nil && s.Protocol == "https" {
+		client = &http.Client{Transport: t}
+	}
 
 	//SEND REQUEST
-	resp, err := http.Post(url, "text/json", bytes.NewBuffer(r.Encode()))
+	resp, err := client.Post(url, "text/json", bytes.NewBuffer(r.Encode()))
 	if err != nil {
 		log.Println(err)
 	}
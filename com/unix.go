@@ -0,0 +1,76 @@
+package com
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Unix - Protocol name for unix domain sockets, address being the socket path
+const Unix = "unix"
+
+var unixTransports sync.Map
+
+// Listen - Listen on address and port, or on socket path when protocol is unix
+func Listen(protocol string, address string, port string, mode os.FileMode) (net.Listener, error) {
+	if protocol != Unix {
+		return net.Listen("tcp", address+":"+port)
+	}
+
+	//REMOVE STALE SOCKET FILE
+	if fi, err := os.Lstat(address); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New(address + " exists and is not a socket")
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(address, mode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// ParseFileMode - Parse octal file mode (example : 0660), empty string being 0
+func ParseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, errors.New("invalid file mode \"" + mode + "\"")
+	}
+	return os.FileMode(m), nil
+}
+
+// UnixTransport - Shared HTTP transport dialing socket path whatever the request host
+func UnixTransport(path string) *http.Transport {
+	if t, ok := unixTransports.Load(path); ok {
+		return t.(*http.Transport)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		},
+		MaxIdleConns:    100,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	actual, _ := unixTransports.LoadOrStore(path, t)
+	return actual.(*http.Transport)
+}
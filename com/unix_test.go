@@ -0,0 +1,103 @@
+package com
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "woxy.sock")
+
+	//STALE SOCKET LEFT BY A PREVIOUS RUN
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := Listen(Unix, sock, "", 0640)
+	if err != nil {
+		t.Fatalf("stale socket not replaced : %v", err)
+	}
+	defer l.Close()
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0640 {
+		t.Errorf("socket mode %v, want socket 0640", fi.Mode())
+	}
+
+	//REGULAR FILES ARE NEVER REMOVED
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(Unix, file, "", 0); err == nil {
+		t.Error("listening over a regular file")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file removed : %v", err)
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		mode  string
+		want  os.FileMode
+		valid bool
+	}{
+		{"", 0, true},
+		{"0660", 0660, true},
+		{"600", 0600, true},
+		{"0777", 0777, true},
+		{"0668", 0, false},
+		{"rw-rw----", 0, false},
+		{"-1", 0, false},
+		{"0x1ff", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseFileMode(tt.mode)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseFileMode(%q) = %v, %v, want %v, valid %v", tt.mode, got, err, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestUnixTransport(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "module.sock")
+	l, err := Listen(Unix, sock, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+" "+r.URL.Path)
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	tr := UnixTransport(sock)
+	if UnixTransport(sock) != tr {
+		t.Error("transport not shared for the same socket")
+	}
+
+	//SOCKET DIALED WHATEVER THE REQUEST HOST
+	client := &http.Client{Transport: tr}
+	resp, err := client.Get("http://module.example/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "module.example /index" {
+		t.Errorf("body %q", body)
+	}
+}
@@ -0,0 +1,361 @@
+package com
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultIdleTimeout - Upgraded and stream connections lifetime without traffic in any direction
+var DefaultIdleTimeout = 5 * time.Minute
+
+// IsUpgrade - Request asks to switch protocol (WebSocket, h2c)
+func IsUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeHeaders - Client connection tokens kept for module, HTTP2-Settings only going with h2c upgrades
+func upgradeHeaders(h http.Header) {
+	h2c := strings.EqualFold(h.Get("Upgrade"), "h2c")
+	tokens := []string{"Upgrade"}
+	for _, v := range h.Values("Connection") {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" || strings.EqualFold(t, "upgrade") || (!h2c && strings.EqualFold(t, "HTTP2-Settings")) {
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+	}
+	if !h2c {
+		h.Del("HTTP2-Settings")
+	}
+	h.Set("Connection", strings.Join(tokens, ", "))
+}
+
+// IdleTimeout - Upgraded and stream connections idle timeout of module
+func (rc *RouteConfig) IdleTimeout() time.Duration {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	if rc.IDLE_TIMEOUT > 0 {
+		return rc.IDLE_TIMEOUT
+	}
+	return DefaultIdleTimeout
+}
+
+// ConnTracker - Active upgraded and stream connections of a module
+type ConnTracker struct {
+	mux   sync.Mutex
+	conns map[trackedConn]struct{}
+	total uint64
+}
+
+// trackedConn - Connection shut down by CloseAll
+type trackedConn interface {
+	shutdown()
+}
+
+// closerConn - Tracked connection only needing to be closed
+type closerConn struct {
+	io.Closer
+}
+
+func (c *closerConn) shutdown() {
+	c.Close()
+}
+
+func (t *ConnTracker) add(tc trackedConn) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.conns == nil {
+		t.conns = map[trackedConn]struct{}{}
+	}
+	t.conns[tc] = struct{}{}
+	t.total++
+}
+
+func (t *ConnTracker) remove(tc trackedConn) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.conns, tc)
+}
+
+// Track - Count connection and close it with CloseAll, returned func untracking it
+func (t *ConnTracker) Track(c io.Closer) func() {
+	tc := &closerConn{Closer: c}
+	t.add(tc)
+	return func() {
+		t.remove(tc)
+	}
+}
+
+// Active - Number of open upgraded connections
+func (t *ConnTracker) Active() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return len(t.conns)
+}
+
+// Total - Number of upgraded connections since start
+func (t *ConnTracker) Total() uint64 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.total
+}
+
+// CloseAll - Close open connections, WebSocket clients receiving a going away close frame
+func (t *ConnTracker) CloseAll() int {
+	t.mux.Lock()
+	conns := make([]trackedConn, 0, len(t.conns))
+	for tc := range t.conns {
+		conns = append(conns, tc)
+	}
+	t.mux.Unlock()
+
+	var wg sync.WaitGroup
+	for _, tc := range conns {
+		wg.Add(1)
+		go func(tc trackedConn) {
+			defer wg.Done()
+			tc.shutdown()
+		}(tc)
+	}
+	wg.Wait()
+	return len(conns)
+}
+
+// upgradedConn - Client connection tunneled to module
+type upgradedConn struct {
+	client    net.Conn
+	backend   net.Conn
+	websocket bool
+	idle      time.Duration
+	last      int64
+	down      chan struct{}
+	once      sync.Once
+}
+
+func (uc *upgradedConn) touch() {
+	atomic.StoreInt64(&uc.last, time.Now().UnixNano())
+}
+
+func (uc *upgradedConn) active() bool {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&uc.last))) < uc.idle
+}
+
+// pipe - Copy src to dst until error or idle timeout in both directions
+func (uc *upgradedConn) pipe(dst net.Conn, src net.Conn, r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		src.SetReadDeadline(time.Now().Add(uc.idle))
+		n, err := r.Read(buf)
+		if n > 0 {
+			uc.touch()
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			//OTHER DIRECTION STILL ACTIVE
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && uc.active() {
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (uc *upgradedConn) close() {
+	uc.once.Do(func() {
+		uc.backend.Close()
+		uc.client.Close()
+	})
+}
+
+// shutdown - Stop module side first so close frame is not interleaved with module data
+func (uc *upgradedConn) shutdown() {
+	uc.once.Do(func() {
+		uc.backend.Close()
+		uc.client.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		<-uc.down
+		if uc.websocket {
+			uc.client.Write(webSocketCloseFrame(1001, "module stopped"))
+		}
+		uc.client.Close()
+	})
+}
+
+// webSocketCloseFrame - Unmasked server close frame (RFC 6455 5.5.1)
+func webSocketCloseFrame(code uint16, reason string) []byte {
+	frame := []byte{0x88, byte(2 + len(reason)), 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], code)
+	return append(frame, reason...)
+}
+
+// dialBackend - Raw connection to module, TLS being negotiated for HTTP/1.1 only
+func dialBackend(ctx context.Context, binding ServerConfig, target *url.URL) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	switch binding.PROTOCOL {
+	case Unix:
+		return dialer.DialContext(ctx, "unix", binding.ADDRESS)
+	case "https":
+		cfg := &tls.Config{}
+		if t := PeerTransport(); t != nil {
+			cfg = t.TLSClientConfig.Clone()
+		}
+		cfg.ServerName = target.Hostname()
+		cfg.NextProtos = []string{"http/1.1"}
+		return (&tls.Dialer{NetDialer: dialer, Config: cfg}).DialContext(ctx, "tcp", target.Host)
+	}
+	return dialer.DialContext(ctx, "tcp", target.Host)
+}
+
+// proxyUpgrade - Forward upgrade request to module then tunnel both connections
+func proxyUpgrade(ctx *Context, target *url.URL) {
+	routeConfig := ctx.RouteConfig
+	binding := routeConfig.Binding()
+
+	backend, err := dialBackend(ctx.Request.Context(), binding, target)
+	if err != nil {
+		ctx.proxyError(err)
+		return
+	}
+
+	//REQUEST TO MODULE, KEEPING UPGRADE HEADERS
+	out := ctx.Request.Clone(ctx.Request.Context())
+	out.URL = &url.URL{Path: target.Path, RawPath: target.RawPath, RawQuery: target.RawQuery}
+	out.Host = target.Host
+	out.RequestURI = ""
+	upgradeHeaders(out.Header)
+	forwardHeaders(out, ctx)
+	if host, _, err := net.SplitHostPort(ctx.RemoteAddr); err == nil {
+		if prior := out.Header[HeaderForwardedFor]; len(prior) > 0 {
+			host = strings.Join(prior, ", ") + ", " + host
+		}
+		out.Header.Set(HeaderForwardedFor, host)
+	}
+
+	if err := out.Write(backend); err != nil {
+		backend.Close()
+		ctx.proxyError(err)
+		return
+	}
+
+	br := bufio.NewReader(backend)
+	res, err := http.ReadResponse(br, out)
+	if err != nil {
+		backend.Close()
+		ctx.proxyError(err)
+		return
+	}
+	rewriteLocations(res.Header, ctx, routeConfig.moduleProxy().base)
+	rewriteResponseHeaders(res.Header, ctx)
+
+	//MODULE REFUSED UPGRADE : FORWARD ITS RESPONSE
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		if err := interceptStatus(res, routeConfig); err != nil {
+			backend.Close()
+			ctx.proxyError(err)
+			return
+		}
+		defer backend.Close()
+		defer res.Body.Close()
+		for k, v := range res.Header {
+			ctx.ResponseWriter.Header()[k] = v
+		}
+		ctx.ResponseWriter.WriteHeader(res.StatusCode)
+		io.Copy(ctx.ResponseWriter, res.Body)
+		return
+	}
+
+	if !strings.EqualFold(res.Header.Get("Upgrade"), ctx.Request.Header.Get("Upgrade")) {
+		backend.Close()
+		ctx.proxyError(errors.New("module switched to unexpected protocol " + res.Header.Get("Upgrade")))
+		return
+	}
+
+	//HTTP/2 AND HTTP/3 CONNECTIONS CAN'T BE HIJACKED
+	client, brw, err := http.NewResponseController(ctx.ResponseWriter).Hijack()
+	if err != nil {
+		backend.Close()
+		ctx.proxyError(err)
+		return
+	}
+
+	//REQUEST TIMEOUTS DON'T APPLY ANYMORE
+	client.SetDeadline(time.Time{})
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	res.Header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		client.Close()
+		backend.Close()
+		return
+	}
+
+	routeConfig.tunnel(client, brw.Reader, backend, br, strings.EqualFold(res.Header.Get("Upgrade"), "websocket"))
+}
+
+// Tunnel - Copy bytes between client and module connections until one side closes or idles
+func (rc *RouteConfig) Tunnel(client net.Conn, backend net.Conn) {
+	rc.tunnel(client, client, backend, backend, false)
+}
+
+// tunnel - Tracked tunnel, readers holding bytes already buffered from connections
+func (rc *RouteConfig) tunnel(client net.Conn, cr io.Reader, backend net.Conn, br io.Reader, websocket bool) {
+	uc := &upgradedConn{
+		client:    client,
+		backend:   backend,
+		websocket: websocket,
+		idle:      rc.IdleTimeout(),
+		down:      make(chan struct{}),
+	}
+	uc.touch()
+
+	tracker := rc.Upgrades()
+	tracker.add(uc)
+	defer tracker.remove(uc)
+
+	up := make(chan struct{})
+	go func() {
+		uc.pipe(backend, client, cr)
+		close(up)
+	}()
+	go func() {
+		uc.pipe(client, backend, br)
+		close(uc.down)
+	}()
+
+	select {
+	case <-up:
+	case <-uc.down:
+	}
+	uc.close()
+	<-up
+	<-uc.down
+	log.Println("GO-WOXY Core - Connection to", rc.NAME, "closed")
+}
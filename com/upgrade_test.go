@@ -0,0 +1,174 @@
+package com
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoModule - Module switching to requested protocol then echoing bytes, upgrade request headers sent on seen
+func echoModule(t *testing.T, seen chan<- http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			seen <- r.Header.Clone()
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: " + r.Header.Get("Upgrade") + "\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw)
+	})
+}
+
+// dialUpgrade - Send upgrade request through router server, return connection after 101 response
+func dialUpgrade(t *testing.T, addr string, headers string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET /ws/echo HTTP/1.1\r\nHost: "+addr+"\r\n"+headers+"\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status %d, want 101", res.StatusCode)
+	}
+	return conn, br
+}
+
+func TestUpgradeWebSocketEcho(t *testing.T) {
+	seen := make(chan http.Header, 1)
+	r, rc := testRouter(t, echoModule(t, seen), Route{FROM: "/ws", TO: "/"})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, br := dialUpgrade(t, srv.Listener.Addr().String(), "Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\nHTTP2-Settings: AAMAAABkAAQCAAAAAAIAAAAA\r\n")
+
+	h := <-seen
+	if h.Get("Sec-WebSocket-Key") == "" || !headerHasToken(h, "Connection", "upgrade") || !headerHasToken(h, "Connection", "keep-alive") {
+		t.Errorf("module upgrade headers : %v", h)
+	}
+	if h.Get("HTTP2-Settings") != "" {
+		t.Error("HTTP2-Settings forwarded without h2c upgrade")
+	}
+
+	for _, msg := range []string{"hello", "world"} {
+		io.WriteString(conn, msg)
+		b := make([]byte, len(msg))
+		if _, err := io.ReadFull(br, b); err != nil || string(b) != msg {
+			t.Fatalf("echo %q, err %v, want %q", b, err, msg)
+		}
+	}
+	if n := rc.Upgrades().Active(); n != 1 {
+		t.Errorf("active connections = %d, want 1", n)
+	}
+}
+
+func TestUpgradeH2CSettings(t *testing.T) {
+	seen := make(chan http.Header, 1)
+	r, _ := testRouter(t, echoModule(t, seen), Route{FROM: "/ws", TO: "/"})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	dialUpgrade(t, srv.Listener.Addr().String(), "Upgrade: h2c\r\nConnection: Upgrade, HTTP2-Settings\r\nHTTP2-Settings: AAMAAABkAAQCAAAAAAIAAAAA\r\n")
+
+	h := <-seen
+	if h.Get("HTTP2-Settings") != "AAMAAABkAAQCAAAAAAIAAAAA" || !headerHasToken(h, "Connection", "HTTP2-Settings") {
+		t.Errorf("h2c settings not forwarded : %v", h)
+	}
+}
+
+func TestUpgradeCloseAll(t *testing.T) {
+	r, rc := testRouter(t, echoModule(t, nil), Route{FROM: "/ws", TO: "/"})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, br := dialUpgrade(t, srv.Listener.Addr().String(), "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	io.WriteString(conn, "ping")
+	io.ReadFull(br, make([]byte, 4))
+
+	if n := rc.Upgrades().CloseAll(); n != 1 {
+		t.Fatalf("CloseAll() = %d, want 1", n)
+	}
+
+	//GOING AWAY CLOSE FRAME THEN END OF CONNECTION
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(webSocketCloseFrame(1001, "module stopped")); string(rest) != want {
+		t.Errorf("client got %q, want close frame %q", rest, want)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for rc.Upgrades().Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := rc.Upgrades().Active(); n != 0 {
+		t.Errorf("active connections = %d after CloseAll, want 0", n)
+	}
+}
+
+func TestUpgradeIdleTimeout(t *testing.T) {
+	r, rc := testRouter(t, echoModule(t, nil), Route{FROM: "/ws", TO: "/"})
+	rc.IDLE_TIMEOUT = 100 * time.Millisecond
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, br := dialUpgrade(t, srv.Listener.Addr().String(), "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+
+	//TRAFFIC KEEPS TUNNEL OPEN PAST IDLE TIMEOUT
+	for i := 0; i < 4; i++ {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(conn, "x")
+		if _, err := br.ReadByte(); err != nil {
+			t.Fatalf("tunnel closed while active : %v", err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Fatalf("read after idle = %v, want EOF", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("idle tunnel closed after %v", d)
+	}
+}
+
+func TestUpgradeHeaders(t *testing.T) {
+	tests := []struct {
+		upgrade    string
+		connection string
+		want       string
+		settings   bool
+	}{
+		{"websocket", "Upgrade", "Upgrade", false},
+		{"websocket", "keep-alive, upgrade", "Upgrade, keep-alive", false},
+		{"websocket", "Upgrade, HTTP2-Settings", "Upgrade", false},
+		{"h2c", "Upgrade, HTTP2-Settings", "Upgrade, HTTP2-Settings", true},
+	}
+	for _, tt := range tests {
+		h := http.Header{"Upgrade": {tt.upgrade}, "Connection": {tt.connection}, "Http2-Settings": {"AAMAAABk"}}
+		upgradeHeaders(h)
+		if got := h.Get("Connection"); !strings.EqualFold(got, tt.want) {
+			t.Errorf("%s %q : Connection = %q, want %q", tt.upgrade, tt.connection, got, tt.want)
+		}
+		if got := h.Get("HTTP2-Settings") != ""; got != tt.settings {
+			t.Errorf("%s %q : HTTP2-Settings kept = %v, want %v", tt.upgrade, tt.connection, got, tt.settings)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package com
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen - Module request refused while its circuit is open
+var ErrCircuitOpen = errors.New("circuit open, module failing")
+
+// CircuitConfig - Breaker opening after consecutive module failures, half-opening after cooldown
+type CircuitConfig struct {
+	COOLDOWN time.Duration
+	FAILURES int
+}
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// UpstreamStats - Module circuit and retries seen by the proxy
+type UpstreamStats struct {
+	CIRCUIT  string
+	FAILURES int
+	SINCE    time.Time
+	RETRIES  uint64
+}
+
+// circuitBreaker - Breaker of one module instance, a single probe going through once half-open
+type circuitBreaker struct {
+	mux      sync.Mutex
+	cfg      CircuitConfig
+	state    string
+	failures int
+	since    time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(cfg CircuitConfig) *circuitBreaker {
+	return &circuitBreaker{cfg: cfg, state: CircuitClosed, since: time.Now()}
+}
+
+// allow - Request can be sent to module
+func (cb *circuitBreaker) allow() error {
+	if cb.cfg.FAILURES < 0 {
+		return nil
+	}
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.since) < cb.cfg.COOLDOWN {
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probing = true
+	case CircuitHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// done - Record request outcome
+func (cb *circuitBreaker) done(success bool) {
+	if cb.cfg.FAILURES < 0 {
+		return
+	}
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.probing = false
+	if success {
+		cb.failures = 0
+		if cb.state != CircuitClosed {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.cfg.FAILURES {
+		cb.setState(CircuitOpen)
+	}
+}
+
+// release - Let another probe through, request outcome being unknown
+func (cb *circuitBreaker) release() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.probing = false
+}
+
+func (cb *circuitBreaker) setState(state string) {
+	cb.state = state
+	cb.since = time.Now()
+}
+
+// retryBudget - Retries allowed as a ratio of requests over a rolling window
+type retryBudget struct {
+	mux      sync.Mutex
+	ratio    float64
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// Retry budget window and retries always allowed in it
+const (
+	retryBudgetWindow     = 10 * time.Second
+	retryBudgetMinRetries = 10
+	maxRetryBackoff       = 2 * time.Second
+)
+
+func (rb *retryBudget) roll() {
+	if now := time.Now(); now.Sub(rb.start) > retryBudgetWindow {
+		rb.start, rb.requests, rb.retries = now, 0, 0
+	}
+}
+
+func (rb *retryBudget) request() {
+	rb.mux.Lock()
+	defer rb.mux.Unlock()
+	rb.roll()
+	rb.requests++
+}
+
+// withdraw - Take one retry from budget
+func (rb *retryBudget) withdraw() bool {
+	rb.mux.Lock()
+	defer rb.mux.Unlock()
+	rb.roll()
+	if rb.retries >= retryBudgetMinRetries+int(rb.ratio*float64(rb.requests)) {
+		return false
+	}
+	rb.retries++
+	return true
+}
+
+// upstreamTransport - Module transport retrying idempotent requests and breaking circuit on failures
+type upstreamTransport struct {
+	retried uint64
+	base    http.RoundTripper
+	breaker *circuitBreaker
+	budget  *retryBudget
+	backoff time.Duration
+	retries int
+}
+
+func newUpstreamTransport(base http.RoundTripper, pc ProxyConfig) *upstreamTransport {
+	return &upstreamTransport{
+		base:    base,
+		breaker: newCircuitBreaker(pc.CIRCUIT),
+		budget:  &retryBudget{ratio: pc.RETRY_BUDGET, start: time.Now()},
+		backoff: pc.RETRY_BACKOFF,
+		retries: pc.RETRIES,
+	}
+}
+
+// idempotent - Method can be sent again, requests with a body never being replayed
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// failed - Module didn't answer or answered it can't serve
+func failed(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (ut *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := ut.breaker.allow(); err != nil {
+		return nil, err
+	}
+	ut.budget.request()
+	retryable := ut.retries > 0 && idempotent(req)
+
+	for attempt := 0; ; attempt++ {
+		res, err := ut.base.RoundTrip(req)
+
+		//CLIENT GONE, SAYING NOTHING ABOUT MODULE
+		if err != nil && errors.Is(context.Cause(req.Context()), context.Canceled) {
+			ut.breaker.release()
+			return res, err
+		}
+		fail := failed(res, err)
+		ut.breaker.done(!fail)
+
+		if !fail || !retryable || attempt >= ut.retries || !ut.budget.withdraw() || ut.breaker.allow() != nil {
+			return res, err
+		}
+
+		//DROP FAILED RESPONSE BEFORE TRYING AGAIN
+		if res != nil {
+			io.CopyN(io.Discard, res.Body, 4096)
+			res.Body.Close()
+		}
+		atomic.AddUint64(&ut.retried, 1)
+		if err := sleepContext(req.Context(), ut.delay(attempt)); err != nil {
+			ut.breaker.release()
+			return nil, err
+		}
+	}
+}
+
+// delay - Exponential backoff with full jitter
+func (ut *upstreamTransport) delay(attempt int) time.Duration {
+	d := ut.backoff << uint(attempt)
+	if d <= 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// stats - Current circuit state and retries
+func (ut *upstreamTransport) stats() UpstreamStats {
+	ut.breaker.mux.Lock()
+	defer ut.breaker.mux.Unlock()
+	return UpstreamStats{CIRCUIT: ut.breaker.state, FAILURES: ut.breaker.failures, SINCE: ut.breaker.since, RETRIES: atomic.LoadUint64(&ut.retried)}
+}
@@ -0,0 +1,187 @@
+package com
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := newCircuitBreaker(CircuitConfig{FAILURES: 2, COOLDOWN: 50 * time.Millisecond})
+	step := func(name string, err error, state string) {
+		t.Helper()
+		if cb.state != state {
+			t.Fatalf("%s : state %s, want %s", name, cb.state, state)
+		}
+		if err != nil {
+			t.Fatalf("%s : %v", name, err)
+		}
+	}
+
+	step("closed", cb.allow(), CircuitClosed)
+	cb.done(false)
+	step("one failure", cb.allow(), CircuitClosed)
+	cb.done(true)
+	cb.done(false)
+	step("success resetting failures", nil, CircuitClosed)
+	cb.done(false)
+	if err := cb.allow(); err != ErrCircuitOpen || cb.state != CircuitOpen {
+		t.Fatalf("after failures : %v, state %s", err, cb.state)
+	}
+
+	//ONE PROBE ONCE COOLDOWN IS OVER, FAILING PROBE OPENING AGAIN
+	time.Sleep(60 * time.Millisecond)
+	step("probe", cb.allow(), CircuitHalfOpen)
+	if err := cb.allow(); err != ErrCircuitOpen {
+		t.Fatalf("second probe : %v", err)
+	}
+	cb.done(false)
+	if err := cb.allow(); err != ErrCircuitOpen || cb.state != CircuitOpen {
+		t.Fatalf("failed probe : %v, state %s", err, cb.state)
+	}
+
+	//RELEASED PROBE LETTING ANOTHER ONE THROUGH, SUCCESSFUL PROBE CLOSING
+	time.Sleep(60 * time.Millisecond)
+	step("probe", cb.allow(), CircuitHalfOpen)
+	cb.release()
+	step("probe after release", cb.allow(), CircuitHalfOpen)
+	cb.done(true)
+	step("closed after probe", cb.allow(), CircuitClosed)
+
+	disabled := newCircuitBreaker(CircuitConfig{FAILURES: -1})
+	for i := 0; i < 10; i++ {
+		disabled.done(false)
+	}
+	if err := disabled.allow(); err != nil {
+		t.Errorf("disabled breaker : %v", err)
+	}
+}
+
+// failingModule - Module answering 503 to its first failures requests, hits counted
+func failingModule(t *testing.T, failures int64, hits *int64) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(hits, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUpstreamTransportRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     io.Reader
+		retries  int
+		failures int64
+		code     int
+		hits     int64
+	}{
+		{"get retried until success", http.MethodGet, nil, 2, 2, http.StatusOK, 3},
+		{"get retries exhausted", http.MethodGet, nil, 2, 5, http.StatusServiceUnavailable, 3},
+		{"post not retried", http.MethodPost, nil, 2, 1, http.StatusServiceUnavailable, 1},
+		{"put with body not retried", http.MethodPut, strings.NewReader("x"), 2, 1, http.StatusServiceUnavailable, 1},
+		{"retries disabled", http.MethodGet, nil, -1, 1, http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int64
+			srv := failingModule(t, tt.failures, &hits)
+			ut := newUpstreamTransport(http.DefaultTransport, ProxyConfig{RETRIES: tt.retries, RETRY_BACKOFF: time.Millisecond, RETRY_BUDGET: 0.2, CIRCUIT: CircuitConfig{FAILURES: -1}})
+
+			req, _ := http.NewRequest(tt.method, srv.URL, tt.body)
+			res, err := ut.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code || atomic.LoadInt64(&hits) != tt.hits {
+				t.Errorf("got %d after %d hits, want %d after %d", res.StatusCode, hits, tt.code, tt.hits)
+			}
+			if retried := ut.stats().RETRIES; retried != uint64(tt.hits-1) {
+				t.Errorf("retries = %d, want %d", retried, tt.hits-1)
+			}
+		})
+	}
+}
+
+func TestUpstreamTransportCircuit(t *testing.T) {
+	var hits int64
+	srv := failingModule(t, 100, &hits)
+	ut := newUpstreamTransport(http.DefaultTransport, ProxyConfig{RETRIES: -1, CIRCUIT: CircuitConfig{FAILURES: 3, COOLDOWN: time.Minute}})
+
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		res, err := ut.RoundTrip(req)
+		if i < 3 {
+			if err != nil {
+				t.Fatalf("request %d : %v", i, err)
+			}
+			res.Body.Close()
+		} else if err != ErrCircuitOpen {
+			t.Fatalf("request %d : %v, want circuit open", i, err)
+		}
+	}
+	if n := atomic.LoadInt64(&hits); n != 3 {
+		t.Errorf("module hits = %d, want 3", n)
+	}
+	if s := ut.stats(); s.CIRCUIT != CircuitOpen || s.FAILURES != 3 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestProxyTimeout(t *testing.T) {
+	r, rc := testRouter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/events" {
+			//EVENTS KEEP COMING PAST TOTAL TIMEOUT
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 4; i++ {
+				fmt.Fprintf(w, "data: %d\n\n", i)
+				w.(http.Flusher).Flush()
+				time.Sleep(40 * time.Millisecond)
+			}
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}), Route{FROM: "/app", TO: "/"})
+	rc.PROXY = ProxyConfig{TIMEOUT: 80 * time.Millisecond, RETRIES: -1}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/app/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("slow module : status %d, want 504", res.StatusCode)
+	}
+
+	res, err = http.Get(srv.URL + "/app/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	events := 0
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "data: ") {
+			events++
+		}
+	}
+	if events != 4 || sc.Err() != nil {
+		t.Errorf("event stream : %d events, err %v, want 4", events, sc.Err())
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+/*ACMEConfig - Automatic certificate issuance configuration */
+type ACMEConfig struct {
+	CA_ROOTS     string
+	CHALLENGES   []string
+	DIRECTORY    string
+	EMAIL        string
+	ENABLED      bool
+	HOSTS        []string
+	HTTP_PORT    string
+	RENEW_BEFORE time.Duration
+	STORAGE      string
+}
+
+// HTTPChallenge - HTTP-01 challenge enabled
+func (ac *ACMEConfig) HTTPChallenge() bool {
+	return ac.challenge("http-01")
+}
+
+// ALPNChallenge - TLS-ALPN-01 challenge enabled
+func (ac *ACMEConfig) ALPNChallenge() bool {
+	return ac.challenge("tls-alpn-01")
+}
+
+func (ac *ACMEConfig) challenge(name string) bool {
+	for _, c := range ac.CHALLENGES {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) checkACME() {
+	acmeConfig := &c.SERVER.ACME
+	if !acmeConfig.ENABLED {
+		return
+	}
+
+	if acmeConfig.DIRECTORY == "" {
+		acmeConfig.DIRECTORY = autocert.DefaultACMEDirectory
+	}
+	if len(acmeConfig.CHALLENGES) == 0 {
+		acmeConfig.CHALLENGES = []string{"http-01", "tls-alpn-01"}
+	}
+	if acmeConfig.HTTP_PORT == "" {
+		acmeConfig.HTTP_PORT = "80"
+	}
+	if acmeConfig.RENEW_BEFORE == 0 {
+		acmeConfig.RENEW_BEFORE = 30 * 24 * time.Hour
+	}
+	if acmeConfig.STORAGE == "" {
+		acmeConfig.STORAGE = "certs" + string(os.PathSeparator) + "acme"
+	}
+}
+
+// newACMEManager - Certificate manager issuing and renewing certificates of served hostnames
+func (core *Core) newACMEManager() (*autocert.Manager, error) {
+	cfg := core.config.SERVER.ACME
+
+	client := &acme.Client{DirectoryURL: cfg.DIRECTORY}
+
+	//TRUST CUSTOM ROOTS FOR LOCAL ACME SERVER (PEBBLE)
+	if cfg.CA_ROOTS != "" {
+		pem, err := os.ReadFile(cfg.CA_ROOTS)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + cfg.CA_ROOTS)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+	}
+
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cfg.STORAGE),
+		HostPolicy:  core.acmeHostPolicy,
+		RenewBefore: cfg.RENEW_BEFORE,
+		Email:       cfg.EMAIL,
+		Client:      client,
+	}, nil
+}
+
+// acmeHostPolicy - Only issue certificates for configured hosts and hosts bound by routes
+func (core *Core) acmeHostPolicy(ctx context.Context, host string) error {
+	for _, h := range core.config.SERVER.ACME.HOSTS {
+		if strings.EqualFold(h, host) {
+			return nil
+		}
+	}
+	for _, h := range core.router.Hosts() {
+		if strings.EqualFold(h, host) {
+			return nil
+		}
+	}
+	return errors.New("acme: host " + host + " not served by go-woxy")
+}
+
+// serveACMEChallenges - Plain http listener answering HTTP-01 challenges
+func (core *Core) serveACMEChallenges() *http.Server {
+	cfg := core.config.SERVER.ACME
+	addr := core.config.SERVER.ADDRESS + ":" + cfg.HTTP_PORT
+	log.Println("GO-WOXY Core - Serving ACME challenges at http://" + addr)
+
+	listener, err := com.Listen("http", core.config.SERVER.ADDRESS, cfg.HTTP_PORT, 0)
+	if err != nil {
+		log.Fatal("GO-WOXY Core - ", err)
+	}
+
+	s := &http.Server{
+		Addr:         addr,
+		Handler:      core.acme.HTTPHandler(nil),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("GO-WOXY Core - ACME %v", err)
+		}
+	}()
+	return s
+}
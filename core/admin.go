@@ -0,0 +1,80 @@
+package core
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// adminRouter - Router serving control endpoints
+// Without dedicated admin listener they are served by the public router
+func (core *Core) adminRouter() *com.Router {
+	if !core.config.ADMIN.Enabled() {
+		return core.router
+	}
+
+	router := com.NewRouter(com.Error404())
+	router.Middlewares = append(router.Middlewares, core.logMiddleware())
+	router.TrustedProxies = core.router.TrustedProxies
+	return router
+}
+
+// serveAdmin - Start admin listener serving only control endpoints
+func (core *Core) serveAdmin(router *com.Router) *http.Server {
+	admin := core.config.ADMIN
+	addr := admin.ADDRESS
+	if admin.PROTOCOL != com.Unix {
+		addr += ":" + admin.PORT
+	}
+	log.Println("GO-WOXY Core - Serving admin endpoints at " + admin.PROTOCOL + "://" + addr)
+
+	mode, err := com.ParseFileMode(admin.SOCKET_MODE)
+	if err != nil {
+		log.Fatal("GO-WOXY Core - Admin ", err)
+	}
+
+	listener, err := com.Listen(admin.PROTOCOL, admin.ADDRESS, admin.PORT, mode)
+	if err != nil {
+		log.Fatal("GO-WOXY Core - ", err)
+	}
+
+	//MUTUAL TLS WITH MODULES
+	if admin.PROTOCOL == "https" {
+		listener = tls.NewListener(listener, com.GetPeerTLS().ServerConfig())
+	}
+
+	s := &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("GO-WOXY Core - Admin %v", err)
+		}
+	}()
+	return s
+}
+
+// controlProtocol - Protocol control endpoints are served with
+func (core *Core) controlProtocol() string {
+	if core.config.ADMIN.Enabled() {
+		return core.config.ADMIN.PROTOCOL
+	}
+	return core.config.SERVER.primary().PROTOCOL
+}
+
+// HubServer - Address modules use to reach control endpoints
+func (core *Core) HubServer() com.Server {
+	if admin := core.config.ADMIN; admin.Enabled() {
+		return com.Server{IP: com.IP(admin.ADDRESS), Port: com.Port(admin.PORT), Protocol: com.Protocol(admin.PROTOCOL)}
+	}
+
+	lc := core.config.SERVER.primary()
+	return com.Server{IP: com.IP(lc.ADDRESS), Port: com.Port(lc.PORT), Protocol: com.Protocol(lc.PROTOCOL)}
+}
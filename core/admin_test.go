@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+	"github.com/sirupsen/logrus"
+)
+
+// newControlCore - Core with public router answering 404 and silent access logs
+func newControlCore(admin AdminConfig) *Core {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	core := &Core{
+		config:  &Config{ADMIN: admin},
+		router:  com.NewRouter(com.Error404()),
+		loggers: map[string]*logrus.Logger{"core": logger},
+	}
+	core.config.checkAdmin()
+	return core
+}
+
+// post - Empty control request, answered with an error message by control endpoints
+func post(t *testing.T, h http.Handler, path string, remote string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+	if remote != "" {
+		req.RemoteAddr = remote
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestControlEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		admin  AdminConfig
+		public bool
+	}{
+		{"public router without admin listener", AdminConfig{}, true},
+		{"admin listener port set", AdminConfig{PORT: "9090"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := newControlCore(tt.admin)
+			admin, err := core.controlRouter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (admin == core.router) != tt.public {
+				t.Fatalf("control endpoints on public router = %v, want %v", admin == core.router, tt.public)
+			}
+
+			for _, path := range []string{"/connect", "/cmd"} {
+				if w := post(t, admin, path, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Error") {
+					t.Errorf("admin %s : %d %q", path, w.Code, w.Body.String())
+				}
+				if tt.public {
+					continue
+				}
+				if w := post(t, core.router, path, ""); w.Code != http.StatusNotFound {
+					t.Errorf("public %s : status %d, want 404", path, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminUnixListener(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "admin.sock")
+	core := newControlCore(AdminConfig{PROTOCOL: com.Unix, ADDRESS: sock, SOCKET_MODE: "0600"})
+	admin, err := core.controlRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := core.serveAdmin(admin)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %v, want 0600", fi.Mode().Perm())
+	}
+
+	client := &http.Client{Transport: com.UnixTransport(sock), Timeout: 5 * time.Second}
+	resp, err := client.Post("http://unix/cmd", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Empty module hash") {
+		t.Errorf("admin /cmd over unix socket : %q", body)
+	}
+	if w := post(t, core.router, "/cmd", ""); w.Code != http.StatusNotFound {
+		t.Errorf("public /cmd : status %d, want 404", w.Code)
+	}
+}
+
+func TestAdminAccessFilter(t *testing.T) {
+	core := newControlCore(AdminConfig{PORT: "9090", ALLOW: []string{"10.0.0.0/8", "192.0.2.1"}, DENY: []string{"10.0.0.66"}})
+	admin, err := core.controlRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		code   int
+	}{
+		{"allowed network", "10.1.2.3:4000", http.StatusOK},
+		{"allowed address", "192.0.2.1:4000", http.StatusOK},
+		{"denied inside allowed network", "10.0.0.66:4000", http.StatusForbidden},
+		{"not allowed", "203.0.113.7:4000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/connect", "/cmd"} {
+				if w := post(t, admin, path, tt.remote); w.Code != tt.code {
+					t.Errorf("%s : status %d, want %d", path, w.Code, tt.code)
+				}
+			}
+		})
+	}
+
+	core.config.ADMIN.ALLOW = []string{"not-an-ip"}
+	if _, err := core.controlRouter(); err == nil {
+		t.Error("invalid allow list accepted")
+	}
+}
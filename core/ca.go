@@ -0,0 +1,249 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+/*MTLSConfig - Mutual TLS between hub and modules configuration */
+type MTLSConfig struct {
+	ENABLED  bool
+	HOSTS    []string
+	STORAGE  string
+	VALIDITY time.Duration
+}
+
+func (c *Config) checkMTLS() {
+	mtlsConfig := &c.MTLS
+	if !mtlsConfig.ENABLED {
+		return
+	}
+
+	if mtlsConfig.STORAGE == "" {
+		mtlsConfig.STORAGE = "certs" + string(os.PathSeparator) + "ca"
+	}
+	if mtlsConfig.VALIDITY == 0 {
+		mtlsConfig.VALIDITY = 365 * 24 * time.Hour
+	}
+
+	//CONTROL ENDPOINTS ON THEIR OWN TLS LISTENER, PUBLIC ONES NEVER ASKING FOR CLIENT CERTIFICATES
+	if !c.ADMIN.Enabled() {
+		log.Fatalln("GO-WOXY Core - mtls requires an admin listener (admin port or unix socket)")
+	}
+	if c.ADMIN.PROTOCOL == "http" {
+		c.ADMIN.PROTOCOL = "https"
+	}
+
+	//MODULES GETTING A CERTIFICATE SERVE OVER TLS
+	for k, m := range c.MODULES {
+		if m.issuedCertificate() && m.BINDING.PROTOCOL == "http" {
+			m.BINDING.PROTOCOL = "https"
+			c.MODULES[k] = m
+		}
+	}
+}
+
+// certAuthority - Internal CA issuing hub and modules certificates
+type certAuthority struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+// loadOrCreateCA - Load CA from storage, creating it on first start
+func loadOrCreateCA(dir string) (*certAuthority, error) {
+	certFile := filepath.Join(dir, "ca.pem")
+	keyFile := filepath.Join(dir, "ca-key.pem")
+
+	certPEM, err := os.ReadFile(certFile)
+	if os.IsNotExist(err) {
+		return createCA(certFile, keyFile)
+	} else if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, errors.New("invalid internal CA files in " + dir)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &certAuthority{cert: cert, certPEM: certPEM, key: key}, nil
+}
+
+func createCA(certFile string, keyFile string) (*certAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "go-woxy internal CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, keyPEM, err := encodePEM(der, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	log.Println("GO-WOXY Core - Internal CA created in", filepath.Dir(certFile))
+	return &certAuthority{cert: cert, certPEM: certPEM, key: key}, nil
+}
+
+// issue - Certificate valid for client and server authentication
+func (ca *certAuthority) issue(name string, hosts []string, validity time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"go-woxy"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if h != "" {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodePEM(der, key)
+}
+
+func encodePEM(der []byte, key *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
+	return certPEM, keyPEM, nil
+}
+
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error generating certificate serial : ", err)
+	}
+	return n
+}
+
+// setupMTLS - Load internal CA and issue hub certificate
+func (core *Core) setupMTLS() {
+	cfg := core.config.MTLS
+	ca, err := loadOrCreateCA(cfg.STORAGE)
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error loading internal CA : ", err)
+	}
+
+	hosts := append([]string{"localhost", "127.0.0.1", "::1", core.config.ADMIN.ADDRESS}, cfg.HOSTS...)
+	for _, lc := range core.config.SERVER.listeners() {
+		hosts = append(hosts, lc.ADDRESS)
+	}
+	certPEM, keyPEM, err := ca.issue("go-woxy", hosts, cfg.VALIDITY)
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error issuing hub certificate : ", err)
+	}
+	peer, err := com.NewPeerTLS(certPEM, keyPEM, ca.certPEM)
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error loading hub certificate : ", err)
+	}
+
+	core.ca = ca
+	com.SetPeerTLS(peer)
+}
+
+// issueModuleCerts - Write module certificate and internal CA in dir
+func (core *Core) issueModuleCerts(mc *ModuleConfig, dir string) error {
+	hosts := []string{"localhost", "127.0.0.1", "::1", mc.BINDING.ADDRESS, string(mc.hub.IP)}
+	certPEM, keyPEM, err := core.ca.issue(mc.NAME, hosts, core.config.MTLS.VALIDITY)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, com.PeerKeyFile), keyPEM, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, com.PeerCertFile), certPEM, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, com.PeerCAFile), core.ca.certPEM, 0644)
+}
+
+// peerMiddleware - Only modules presenting a certificate issued by internal CA reach control endpoints
+func (core *Core) peerMiddleware() com.MiddlewareFunc {
+	return func(next com.Handler) com.Handler {
+		return com.HandlerFunc(func(ctx *com.Context) {
+			if err := com.GetPeerTLS().Verify(ctx.TLS); err != nil {
+				log.Println("GO-WOXY Core - Rejected control request from", ctx.ClientIP, ":", err)
+				ctx.Text(http.StatusForbidden, "GO-WOXY Core - Forbidden")
+				return
+			}
+			next.Handle(ctx)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// testClientCert - Client certificate issued by ca
+func testClientCert(t *testing.T, ca *certAuthority, name string) tls.Certificate {
+	certPEM, keyPEM, err := ca.issue(name, []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPublicListenerClientAuthWithMTLS(t *testing.T) {
+	core, cert, roots := newTestCore(t)
+	core.router = com.NewRouter(func(ctx *com.Context) {
+		ctx.Text(http.StatusOK, "subject="+ctx.ClientSubject)
+	})
+
+	//INTERNAL CA OF MODULES AND CA OF USERS
+	internal, err := loadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hubPEM, hubKey, err := internal.issue("go-woxy", []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := com.NewPeerTLS(hubPEM, hubKey, internal.certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	com.SetPeerTLS(peer)
+	t.Cleanup(func() { com.SetPeerTLS(nil) })
+	core.ca = internal
+	core.config.ADMIN = AdminConfig{PORT: "2001", PROTOCOL: "https"}
+
+	dir := t.TempDir()
+	users, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCA := filepath.Join(dir, "users.pem")
+	if err := os.WriteFile(clientCA, users.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	//CLIENT AUTH LEFT AS CONFIGURED, NO CERTIFICATE PICKER FOR BROWSERS
+	policies := []struct {
+		clientAuth string
+		want       tls.ClientAuthType
+	}{
+		{"", tls.NoClientCert},
+		{"optional", tls.VerifyClientCertIfGiven},
+		{"required", tls.RequireAndVerifyClientCert},
+	}
+	for _, p := range policies {
+		lc := ListenerConfig{PROTOCOL: "https", CERT: cert.CERT, CERT_KEY: cert.CERT_KEY}
+		if p.clientAuth != "" {
+			lc.TLS = &TLSConfig{CLIENT_AUTH: p.clientAuth, CLIENT_CA: clientCA}
+		}
+		cfg, err := core.getTLSConfig(lc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ClientAuth != p.want || cfg.VerifyConnection != nil {
+			t.Errorf("client_auth %q : ClientAuth = %v, want %v", p.clientAuth, cfg.ClientAuth, p.want)
+		}
+	}
+
+	addr := serveTest(t, core, ListenerConfig{
+		ADDRESS:  "127.0.0.1",
+		PORT:     "0",
+		PROTOCOL: "https",
+		CERT:     cert.CERT,
+		CERT_KEY: cert.CERT_KEY,
+		TLS:      &TLSConfig{CLIENT_AUTH: "optional", CLIENT_CA: clientCA},
+	})
+
+	//MODULE CERTIFICATES ARE UNKNOWN TO PUBLIC LISTENERS
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		want    string
+		wantErr bool
+	}{
+		{"user", []tls.Certificate{testClientCert(t, users, "alice")}, "subject=CN=alice,O=go-woxy", false},
+		{"module", []tls.Certificate{testClientCert(t, internal, "mod")}, "", true},
+		{"no certificate", nil, "subject=", false},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: tt.certs}}}
+		resp, err := client.Get("https://" + addr + "/")
+		if tt.wantErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("%s : request accepted", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s : %v", tt.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.TrimSpace(string(body)) != tt.want {
+			t.Errorf("%s : got %q, want %q", tt.name, body, tt.want)
+		}
+	}
+}
+
+func TestCheckMTLSModuleProtocols(t *testing.T) {
+	c := Config{}
+	c.SERVER.CERT, c.SERVER.CERT_KEY = "cert.pem", "key.pem"
+	c.MTLS.ENABLED = true
+	c.ADMIN = AdminConfig{PORT: "2001", PROTOCOL: "http"}
+	c.MODULES = map[string]ModuleConfig{
+		"git":    {EXE: ModuleExecConfig{SRC: "https://github.com/Wariie/mod.git", MAIN: "main.go"}},
+		"remote": {EXE: ModuleExecConfig{REMOTE: true}},
+		"local":  {EXE: ModuleExecConfig{BIN: "/opt/mod/", MAIN: "main.go"}},
+		"static": {},
+	}
+	for k, m := range c.MODULES {
+		m.BINDING.PROTOCOL = "http"
+		c.MODULES[k] = m
+	}
+	c.checkMTLS()
+
+	if c.ADMIN.PROTOCOL != "https" {
+		t.Errorf("admin protocol = %s, want https", c.ADMIN.PROTOCOL)
+	}
+
+	//ONLY MODULES GIVEN A CERTIFICATE CAN SERVE OVER TLS
+	want := map[string]string{"git": "https", "remote": "https", "local": "http", "static": "http"}
+	for name, protocol := range want {
+		if got := c.MODULES[name].BINDING.PROTOCOL; got != protocol {
+			t.Errorf("module %s protocol = %s, want %s", name, got, protocol)
+		}
+	}
+}
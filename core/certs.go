@@ -0,0 +1,205 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certsReloadInterval - Delay between two certificate files checks
+var certsReloadInterval = 10 * time.Second
+
+/*CertificateConfig - Certificate and key files */
+type CertificateConfig struct {
+	CERT     string
+	CERT_KEY string
+}
+
+// CertificateInfo - Loaded certificate description
+type CertificateInfo struct {
+	CERT      string
+	DEFAULT   bool
+	LISTENER  string
+	SANS      []string
+	NOT_AFTER time.Time
+}
+
+// certStore - Certificates indexed by their names, reloaded when files change
+type certStore struct {
+	mux      sync.RWMutex
+	certs    []*loadedCert
+	listener string
+	names    map[string]*loadedCert
+}
+
+type loadedCert struct {
+	cfg     CertificateConfig
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+	modTime time.Time
+}
+
+// newCertStore - Load certificates, the first config being the default one
+func newCertStore(configs []CertificateConfig) (*certStore, error) {
+	cs := &certStore{}
+	for _, cfg := range configs {
+		lc, err := loadCert(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cs.certs = append(cs.certs, lc)
+	}
+	cs.index()
+	return cs, nil
+}
+
+func loadCert(cfg CertificateConfig) (*loadedCert, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CERT, cfg.CERT_KEY)
+	if err != nil {
+		return nil, errors.New(cfg.CERT + " : " + err.Error())
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, errors.New(cfg.CERT + " : " + err.Error())
+	}
+	cert.Leaf = leaf
+	return &loadedCert{cfg: cfg, cert: &cert, leaf: leaf, modTime: certModTime(cfg)}, nil
+}
+
+// certModTime - Latest modification time of certificate and key files
+func certModTime(cfg CertificateConfig) time.Time {
+	var t time.Time
+	for _, f := range []string{cfg.CERT, cfg.CERT_KEY} {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
+
+// index - Map certificate names to certificates, first declared wins
+func (cs *certStore) index() {
+	names := map[string]*loadedCert{}
+	for _, lc := range cs.certs {
+		for _, name := range certNames(lc.leaf) {
+			if _, ok := names[name]; !ok {
+				names[name] = lc
+			}
+		}
+	}
+	cs.names = names
+}
+
+func certNames(leaf *x509.Certificate) []string {
+	names := make([]string, 0, len(leaf.DNSNames)+len(leaf.IPAddresses)+1)
+	for _, n := range leaf.DNSNames {
+		names = append(names, strings.ToLower(n))
+	}
+	for _, ip := range leaf.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = append(names, strings.ToLower(leaf.Subject.CommonName))
+	}
+	return names
+}
+
+// match - Certificate for server name, exact name first then wildcard
+func (cs *certStore) match(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if name == "" {
+		return nil
+	}
+
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	if lc, ok := cs.names[name]; ok {
+		return lc.cert
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if lc, ok := cs.names["*"+name[i:]]; ok {
+			return lc.cert
+		}
+	}
+	return nil
+}
+
+// fallback - Default certificate
+func (cs *certStore) fallback() *tls.Certificate {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	if len(cs.certs) == 0 {
+		return nil
+	}
+	return cs.certs[0].cert
+}
+
+// reload - Reload certificates whose files changed, keeping the previous one on error
+func (cs *certStore) reload() {
+	cs.mux.RLock()
+	var changed []int
+	for i, lc := range cs.certs {
+		if certModTime(lc.cfg).After(lc.modTime) {
+			changed = append(changed, i)
+		}
+	}
+	cs.mux.RUnlock()
+
+	if len(changed) == 0 {
+		return
+	}
+
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	for _, i := range changed {
+		old := cs.certs[i]
+		lc, err := loadCert(old.cfg)
+		if err != nil {
+			log.Println("GO-WOXY Core - Error reloading certificate", err)
+			old.modTime = certModTime(old.cfg)
+			continue
+		}
+		cs.certs[i] = lc
+		log.Println("GO-WOXY Core - Certificate reloaded :", lc.cfg.CERT)
+	}
+	cs.index()
+}
+
+// watch - Check certificate files for changes until done is closed
+func (cs *certStore) watch(done <-chan bool) {
+	ticker := time.NewTicker(certsReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cs.reload()
+		case <-done:
+			return
+		}
+	}
+}
+
+// Infos - Describe loaded certificates
+func (cs *certStore) Infos() []CertificateInfo {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	infos := make([]CertificateInfo, 0, len(cs.certs))
+	for i, lc := range cs.certs {
+		sans := certNames(lc.leaf)
+		sort.Strings(sans)
+		infos = append(infos, CertificateInfo{
+			CERT:      lc.cfg.CERT,
+			DEFAULT:   i == 0,
+			LISTENER:  cs.listener,
+			SANS:      sans,
+			NOT_AFTER: lc.leaf.NotAfter,
+		})
+	}
+	return infos
+}
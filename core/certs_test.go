@@ -0,0 +1,166 @@
+package core
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert - Certificate named name for hosts written in dir
+func writeCert(t *testing.T, ca *certAuthority, dir string, name string, hosts ...string) CertificateConfig {
+	certPEM, keyPEM, err := ca.issue(name, hosts, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := CertificateConfig{CERT: filepath.Join(dir, name+".pem"), CERT_KEY: filepath.Join(dir, name+".key")}
+	if err := os.WriteFile(cfg.CERT, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.CERT_KEY, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// certName - Common name of certificate, empty without certificate
+func certName(cert *tls.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertStoreMatch(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := newCertStore([]CertificateConfig{
+		writeCert(t, ca, dir, "default", "default.example.org"),
+		writeCert(t, ca, dir, "wildcard", "*.example.com"),
+		writeCert(t, ca, dir, "exact", "api.example.com", "127.0.0.1"),
+		writeCert(t, ca, dir, "duplicate", "api.example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{"api.example.com", "exact"},
+		{"API.Example.com.", "exact"},
+		{"127.0.0.1", "exact"},
+		{"www.example.com", "wildcard"},
+		{"a.b.example.com", ""},
+		{"example.com", ""},
+		{"default.example.org", "default"},
+		{"other.org", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := certName(cs.match(tt.serverName)); got != tt.want {
+			t.Errorf("match(%q) = %q, want %q", tt.serverName, got, tt.want)
+		}
+	}
+
+	//UNMATCHED NAMES GET DEFAULT CERTIFICATE
+	core := &Core{config: &Config{}}
+	getCertificate := core.certificateGetter(cs)
+	for name, want := range map[string]string{"www.example.com": "wildcard", "other.org": "default", "": "default"} {
+		cert, err := getCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil || certName(cert) != want {
+			t.Errorf("certificate for %q = %q, %v, want %q", name, certName(cert), err, want)
+		}
+	}
+
+	empty, _ := newCertStore(nil)
+	if _, err := core.certificateGetter(empty)(&tls.ClientHelloInfo{ServerName: "other.org"}); err == nil {
+		t.Error("certificate given without any")
+	}
+	if infos := cs.Infos(); len(infos) != 4 || !infos[0].DEFAULT || infos[1].DEFAULT {
+		t.Errorf("infos %+v", infos)
+	}
+}
+
+func TestCertStoreReload(t *testing.T) {
+	interval := certsReloadInterval
+	certsReloadInterval = 10 * time.Millisecond
+	t.Cleanup(func() { certsReloadInterval = interval })
+
+	dir := t.TempDir()
+	ca, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := writeCert(t, ca, dir, "site", "site.example.com")
+	cs, err := newCertStore([]CertificateConfig{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	defer close(done)
+	go cs.watch(done)
+
+	// replace - Overwrite certificate files, dated later than loaded ones
+	replace := func(certPEM, keyPEM []byte, at time.Time) {
+		t.Helper()
+		if err := os.WriteFile(cfg.CERT, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cfg.CERT_KEY, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(cfg.CERT, at, at)
+		os.Chtimes(cfg.CERT_KEY, at, at)
+	}
+	// waitFor - Wait until site.example.com is served by certificate named name
+	waitFor := func(name string) bool {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if certName(cs.match("site.example.com")) == name {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	//RENEWED CERTIFICATE PICKED UP WITHOUT RESTART
+	certPEM, keyPEM, err := ca.issue("renewed", []string{"site.example.com", "new.example.com"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace(certPEM, keyPEM, time.Now().Add(time.Minute))
+	if !waitFor("renewed") {
+		t.Fatalf("renewed certificate not loaded, got %q", certName(cs.match("site.example.com")))
+	}
+	if certName(cs.match("new.example.com")) != "renewed" {
+		t.Error("names of renewed certificate not indexed")
+	}
+
+	//BROKEN FILES KEEP PREVIOUS CERTIFICATE
+	replace([]byte("not a certificate"), keyPEM, time.Now().Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := certName(cs.match("site.example.com")); got != "renewed" {
+		t.Errorf("after broken reload got %q, want renewed", got)
+	}
+	if got := certName(cs.fallback()); got != "renewed" {
+		t.Errorf("fallback %q, want renewed", got)
+	}
+
+	//UNCHANGED FILES NOT RELOADED
+	cs.mux.RLock()
+	loaded := cs.certs[0]
+	cs.mux.RUnlock()
+	cs.reload()
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	if cs.certs[0] != loaded {
+		t.Error("unchanged certificate reloaded")
+	}
+}
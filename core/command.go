@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -95,8 +96,11 @@ func (cp *CommandProcessorImpl) Run(name string, core *Core, r *com.Request, m *
 
 // Init - Init CommandProcessorImpl with default commands
 func (cp *CommandProcessorImpl) Init() {
+	cp.Register("Certs", certsCommand)
 	cp.Register("List", listModuleCommand)
 	cp.Register("Log", logModuleCommand)
+	cp.Register("Maintenance", maintenanceCommand)
+	cp.Register("Metrics", metricsCommand)
 	cp.Register("Performance", performanceModuleCommand)
 	cp.Register("Ping", pingCommand)
 	cp.Register("Restart", restartModuleCommand)
@@ -122,8 +126,38 @@ func pingCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (
 	return "Pong", nil
 }
 
+func certsCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (string, error) {
+	infos := []CertificateInfo{}
+	for _, certs := range core.certs {
+		infos = append(infos, certs.Infos()...)
+	}
+	rb, err := json.Marshal(infos)
+	if err != nil {
+		return "Error :", err
+	}
+	return string(rb), nil
+}
+
+// moduleListEntry - Listed module with its circuit state and retries
+type moduleListEntry struct {
+	ModuleConfig
+	UPSTREAM *com.UpstreamStats `json:",omitempty"`
+}
+
 func listModuleCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (string, error) {
-	rb, err := json.Marshal(core.modulesList)
+	list := make([]moduleListEntry, 0, len(core.modulesList))
+	core.routesMux.Lock()
+	for _, m := range core.modulesList {
+		entry := moduleListEntry{ModuleConfig: m}
+		if rc, ok := core.routeConfigs[m.NAME]; ok && m.streamType() == "" {
+			upstream := rc.Upstream()
+			entry.UPSTREAM = &upstream
+		}
+		list = append(list, entry)
+	}
+	core.routesMux.Unlock()
+
+	rb, err := json.Marshal(list)
 	if err != nil {
 		return "Error :", err
 	}
@@ -134,6 +168,57 @@ func logModuleCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...stri
 	return mc.GetLog(), nil
 }
 
+// maintenanceCommand - Turn maintenance 'on' or 'off' for module, or whole instance from hub, current state without content
+func maintenanceCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (string, error) {
+	cr := (*r).(*com.CommandRequest)
+	if mc.streamType() != "" {
+		return "", errors.New("maintenance is not supported by stream modules")
+	}
+
+	//INSTANCE MAINTENANCE ONLY PUBLISHED TO ROUTER, SHARED CONFIG LEFT UNTOUCHED
+	mtc := mc.MAINTENANCE
+	if mc.NAME == "hub" {
+		mtc = core.config.MAINTENANCE
+		mtc.ENABLED = core.router.Maintenance() != nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cr.Content)) {
+	case "":
+	case "on":
+		mtc.ENABLED = true
+	case "off":
+		mtc.ENABLED = false
+	default:
+		return "", errors.New("maintenance expects 'on' or 'off'")
+	}
+
+	m, err := com.NewMaintenance(mtc)
+	if err != nil {
+		return "", err
+	}
+	if mc.NAME == "hub" {
+		core.router.SetMaintenance(m)
+	} else {
+		mc.MAINTENANCE = mtc
+		core.routeConfig(mc)
+	}
+
+	state := "off"
+	if mtc.ENABLED {
+		state = "on"
+	}
+	log.Println("GO-WOXY Core - Maintenance", state, "for", mc.NAME)
+	return "Maintenance " + state, nil
+}
+
+func metricsCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (string, error) {
+	rb, err := json.Marshal(core.GetMetrics())
+	if err != nil {
+		return "Error :", err
+	}
+	return string(rb), nil
+}
+
 func shutdownModuleCommand(core *Core, r *com.Request, mc *ModuleConfig, args ...string) (string, error) {
 	var response string
 	var err error
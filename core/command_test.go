@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// maintenance - Run maintenance command with content on mc
+func maintenance(core *Core, mc *ModuleConfig, content string) (string, error) {
+	var r com.Request = &com.CommandRequest{Command: "Maintenance", Content: content}
+	return maintenanceCommand(core, &r, mc)
+}
+
+func TestMaintenanceCommand(t *testing.T) {
+	core := &Core{
+		config: &Config{MAINTENANCE: com.MaintenanceConfig{MESSAGE: "Back soon"}},
+		router: com.NewRouter(com.Error404()),
+	}
+	hub := &ModuleConfig{NAME: "hub"}
+
+	//INSTANCE MAINTENANCE PUBLISHED TO ROUTER ONLY
+	tests := []struct {
+		content string
+		want    string
+		enabled bool
+	}{
+		{"", "Maintenance off", false},
+		{"on", "Maintenance on", true},
+		{"", "Maintenance on", true},
+		{" OFF ", "Maintenance off", false},
+	}
+	for _, tt := range tests {
+		got, err := maintenance(core, hub, tt.content)
+		if err != nil || got != tt.want {
+			t.Errorf("hub %q : %q, %v, want %q", tt.content, got, err, tt.want)
+		}
+		if (core.router.Maintenance() != nil) != tt.enabled {
+			t.Errorf("hub %q : router maintenance %v, want %v", tt.content, core.router.Maintenance() != nil, tt.enabled)
+		}
+		if core.config.MAINTENANCE.ENABLED {
+			t.Errorf("hub %q : shared config changed", tt.content)
+		}
+	}
+	if _, err := maintenance(core, hub, "maybe"); err == nil {
+		t.Error("invalid content accepted")
+	}
+
+	//MODULE MAINTENANCE GIVEN TO ITS ROUTES
+	mod := &ModuleConfig{NAME: "web", TYPES: "reverse"}
+	if got, err := maintenance(core, mod, "on"); err != nil || got != "Maintenance on" {
+		t.Errorf("module : %q, %v", got, err)
+	}
+	if !mod.MAINTENANCE.ENABLED || core.routeConfig(mod).Maintenance() == nil {
+		t.Error("module maintenance not enabled")
+	}
+
+	//STREAM MODULES REFUSED, NOTHING ENFORCING IT
+	stream := &ModuleConfig{NAME: "db", TYPES: StreamTCP}
+	if _, err := maintenance(core, stream, "on"); err == nil || stream.MAINTENANCE.ENABLED {
+		t.Errorf("stream module maintenance : %v, enabled %v", err, stream.MAINTENANCE.ENABLED)
+	}
+}
@@ -16,16 +16,69 @@ import (
 /*Config - Global configuration */
 type Config struct {
 	ACCESSLOGFILE string
+	ADMIN         AdminConfig
+	MAINTENANCE   com.MaintenanceConfig
 	MODULES       map[string]ModuleConfig
 	MOTD          string
+	MTLS          MTLSConfig
 	NAME          string
+	REDIRECTS     []com.RedirectRule
 	SECRET        string
 	MODDIR        string
 	RESOURCEDIR   string
-	SERVER        com.ServerConfig
+	SERVER        ServerConfig
 	VERSION       int
 }
 
+/*ServerConfig - Core server configuration */
+type ServerConfig struct {
+	com.ServerConfig `mapstructure:",squash"`
+	ACME             ACMEConfig
+	CANONICAL        com.CanonicalConfig
+	CERTIFICATES     []CertificateConfig
+	LISTENERS        []ListenerConfig
+	PROXY_PROTOCOL   bool
+	SOCKET_MODE      string
+	TLS              TLSConfig
+	TRUSTED_PROXIES  []string
+}
+
+// TLSEnabled - Server is served over TLS with static or ACME certificates
+func (sc *ServerConfig) TLSEnabled() bool {
+	return len(sc.certificates()) > 0 || sc.ACME.ENABLED
+}
+
+// certificates - Static certificates, cert and cert_key being the default one
+func (sc *ServerConfig) certificates() []CertificateConfig {
+	var certs []CertificateConfig
+	if sc.CERT != "" && sc.CERT_KEY != "" {
+		certs = append(certs, CertificateConfig{CERT: sc.CERT, CERT_KEY: sc.CERT_KEY})
+	}
+	return append(certs, sc.CERTIFICATES...)
+}
+
+/*AdminConfig - Control endpoints (/connect, /cmd) configuration */
+type AdminConfig struct {
+	ADDRESS     string
+	ALLOW       []string
+	DENY        []string
+	PORT        string
+	PROTOCOL    string
+	SOCKET_MODE string
+}
+
+// Enabled - Control endpoints are served on a dedicated listener
+func (ac *AdminConfig) Enabled() bool {
+	return ac.PORT != "" || ac.PROTOCOL == com.Unix
+}
+
+// middlewares - Middlewares protecting control endpoints
+func (ac *AdminConfig) middlewares() []com.MiddlewareConfig {
+	return []com.MiddlewareConfig{
+		{NAME: "ip-filter", OPTIONS: map[string]interface{}{"allow": ac.ALLOW, "deny": ac.DENY}},
+	}
+}
+
 func (c *Config) LoadConfig(path string) (err error) {
 	//EMPTY CONFIG FILE PATH
 	if len(path) == 0 {
@@ -49,8 +102,18 @@ func (c *Config) LoadConfig(path string) (err error) {
 
 	c.checkServer()
 
+	c.checkListeners()
+
+	c.checkACME()
+
+	c.checkAdmin()
+
 	c.checkModules()
 
+	c.checkStreams()
+
+	c.checkMTLS()
+
 	if c.RESOURCEDIR == "" {
 		c.RESOURCEDIR = "resources" + string(os.PathSeparator)
 	}
@@ -105,6 +168,18 @@ func (c *Config) checkServer() {
 	}
 }
 
+func (c *Config) checkAdmin() {
+
+	if c.ADMIN.PROTOCOL == "" {
+		c.ADMIN.PROTOCOL = "http"
+	}
+
+	//DEDICATED ADMIN LISTENER DEFAULT TO LOOPBACK
+	if c.ADMIN.Enabled() && c.ADMIN.ADDRESS == "" {
+		c.ADMIN.ADDRESS = "127.0.0.1"
+	}
+}
+
 func (c *Config) generateSecret() {
 	if len(c.SECRET) == 0 {
 		b := []byte(tools.String(64))
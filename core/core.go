@@ -4,15 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
-	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -23,21 +22,27 @@ import (
 	"github.com/Wariie/go-woxy/com"
 	auth "github.com/abbot/go-http-auth"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 const Version = "0.0.1"
 
 // Core - GO-WOXY Core Server
 type Core struct {
-	cp          *CommandProcessorImpl
-	config      *Config
-	loggers     map[string]*logrus.Logger
-	modulesList []ModuleConfig
-	mux         sync.Mutex
-	router      *com.Router
-	s           *Supervisor
-	server      *HttpServer
-	roles       []Role
+	acme         *autocert.Manager
+	ca           *certAuthority
+	certs        []*certStore
+	cp           *CommandProcessorImpl
+	config       *Config
+	loggers      map[string]*logrus.Logger
+	modulesList  []ModuleConfig
+	mux          sync.Mutex
+	router       *com.Router
+	routeConfigs map[string]*com.RouteConfig
+	routesMux    sync.Mutex
+	s            *Supervisor
+	server       *HttpServer
+	roles        []Role
 }
 
 // GetConfig - Get go-woxy config
@@ -90,6 +95,13 @@ func (core *Core) GetModule(name string) *ModuleConfig {
 
 // HookAll - Create all binding between module config address and router server
 func (core *Core) HookAll(mc *ModuleConfig) {
+	//STREAM MODULES ARE REACHED THROUGH THEIR OWN PORT
+	if network := mc.streamType(); network != "" {
+		core.routeConfig(mc)
+		log.Println("GO-WOXY Core - Module " + mc.NAME + " - Stream hooked : " + mc.STREAM.Name(network))
+		return
+	}
+
 	routes := mc.BINDING.PATH
 	var err error
 
@@ -105,6 +117,13 @@ func (core *Core) HookAll(mc *ModuleConfig) {
 func (core *Core) Hook(mc *ModuleConfig, r com.Route) error {
 	var err error
 	if len(r.FROM) > 0 {
+		if err = mc.ERRORS.Validate(); err != nil {
+			return err
+		}
+		if _, err = com.NewMaintenance(mc.MAINTENANCE); err != nil {
+			return err
+		}
+
 		var handler com.HandlerFunc
 		if mc.AUTH.ENABLED {
 			_, err = os.Stat(".htpasswd")
@@ -118,14 +137,39 @@ func (core *Core) Hook(mc *ModuleConfig, r com.Route) error {
 			}
 		} else if strings.Contains(mc.TYPES, "bind") {
 			handler = com.FileBind(mc.BINDING.ROOT, r)
+		} else if strings.Contains(mc.TYPES, "grpc") {
+			handler = com.GRPCProxy()
 		} else {
 			handler = com.ReverseProxy()
 		}
 
 		if handler != nil {
-			core.router.Handle(r.FROM, handler, mc.getRouteConfig(), &r)
+			//MODULE THEN ROUTE HEADER RULES, APPLIED BY MODULE PROXY
+			var headers *com.HeaderRewriter
+			headers, err = com.NewHeaderRewriter(mc.HEADERS, r.HEADERS)
+			if err != nil {
+				return err
+			}
+			handler = headers.Handler(handler)
+
+			//PATH REWRITE RULES, FROM AND TO WITHOUT EXPLICIT ONES
+			var rewriter *com.Rewriter
+			rewriter, err = com.NewRewriter(r.RewriteRules()...)
+			if err != nil {
+				return err
+			}
+			handler = rewriter.Handler(handler)
+
+			//COMPILE MODULE THEN ROUTE MIDDLEWARES
+			var chain com.Chain
+			chain, err = com.NewChain(append(com.WithZone(mc.MIDDLEWARES, mc.NAME), com.WithZone(r.MIDDLEWARES, mc.NAME+" "+r.FROM)...)...)
+			if err != nil {
+				return err
+			}
+
+			core.router.Handler(r.FROM, chain.Then(handler), core.routeConfig(mc), &r)
 			log.Println("GO-WOXY Core - Module " + mc.NAME + " - Route created : " + r.FROM + " > " + r.TO)
-		} else {
+		} else if err == nil {
 			err = errors.New("no handler found with this configuration")
 		}
 	}
@@ -139,11 +183,47 @@ func (core *Core) SaveModuleChanges(mc *ModuleConfig) {
 	for i, m := range core.modulesList {
 		if m.NAME == mc.NAME {
 			core.modulesList[i] = *mc
+			core.syncRouteState(mc)
 			return
 		}
 	}
 }
 
+// routeConfig - Route config shared by module routes, updated on each hook
+func (core *Core) routeConfig(mc *ModuleConfig) *com.RouteConfig {
+	core.routesMux.Lock()
+	defer core.routesMux.Unlock()
+	if core.routeConfigs == nil {
+		core.routeConfigs = map[string]*com.RouteConfig{}
+	}
+	rc, ok := core.routeConfigs[mc.NAME]
+	if !ok {
+		rc = &com.RouteConfig{}
+		core.routeConfigs[mc.NAME] = rc
+	}
+	rc.Update(mc.getRouteConfig())
+	return rc
+}
+
+// syncRouteState - Make module state visible to its routes, closing upgraded and stream connections when it goes offline
+func (core *Core) syncRouteState(mc *ModuleConfig) {
+	core.routesMux.Lock()
+	rc, ok := core.routeConfigs[mc.NAME]
+	core.routesMux.Unlock()
+	if !ok || rc.State() == mc.STATE {
+		return
+	}
+
+	rc.SetState(mc.STATE)
+	if mc.STATE != com.Online {
+		go func() {
+			if n := rc.Upgrades().CloseAll(); n > 0 {
+				log.Println("GO-WOXY Core - Module", mc.NAME, "offline - Closed", n, "connections")
+			}
+		}()
+	}
+}
+
 // SearchModWithHash - Thread safe way to get module with his hash
 func (core *Core) SearchModWithHash(hash string) *ModuleConfig {
 	core.mux.Lock()
@@ -160,16 +240,30 @@ func (core *Core) SearchModWithHash(hash string) *ModuleConfig {
 func (core *Core) Setup(mc ModuleConfig, hook bool, modulePath string) (*ModuleConfig, error) {
 	log.Println("GO-WOXY Core - Setup mod : ", mc)
 	if hook && reflect.DeepEqual(mc.EXE, ModuleExecConfig{}) {
-		core.HookAll(&mc)
 		mc.STATE = com.Online
+		core.HookAll(&mc)
 	}
 
 	//IF CONTAINS EXE CONFIG && NOT REMOTE
 	if !reflect.DeepEqual(mc.EXE, ModuleExecConfig{}) {
+		mc.hub = core.HubServer()
 		mc.generateAPIKey()
-		if !mc.EXE.REMOTE && (strings.Contains(mc.EXE.SRC, "http") || strings.Contains(mc.EXE.SRC, "git@")) {
+		if mc.downloaded() {
 			mc.Download(modulePath)
 			mc.copyAPIKey(core.config.SECRET)
+			if core.ca != nil {
+				if err := core.issueModuleCerts(&mc, mc.EXE.BIN); err != nil {
+					log.Println("GO-WOXY Core - Error issuing mod certificate : ", err)
+				}
+			}
+		} else if mc.EXE.REMOTE && core.ca != nil {
+			//REMOTE MODULE FILES ARE COPIED BY HAND LIKE .secret
+			dir := filepath.Join(core.config.MTLS.STORAGE, "modules", mc.NAME)
+			if err := core.issueModuleCerts(&mc, dir); err != nil {
+				log.Println("GO-WOXY Core - Error issuing mod certificate : ", err)
+			} else {
+				log.Println("GO-WOXY Core - Certificate for remote mod", mc.NAME, "issued in", dir)
+			}
 		}
 		mc.STATE = com.Loading
 	}
@@ -179,54 +273,86 @@ func (core *Core) Setup(mc ModuleConfig, hook bool, modulePath string) (*ModuleC
 func (core *Core) launchServer() {
 	log.Println("GO-WOXY Core - Starting")
 
-	//AUTHENTICATION & COMMAND ENDPOINT
 	core.router.DefaultRoute = com.Error404()
-	core.router.Handle("/connect", core.connect(), nil, nil)
-	core.router.Handle("/cmd", core.command(), nil, nil)
+	adminRouter, err := core.controlRouter()
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error creating admin access filter : ", err)
+	}
+
+	core.configAndServe(adminRouter)
+}
+
+// controlRouter - Router serving /connect and /cmd behind admin access filter
+func (core *Core) controlRouter() (*com.Router, error) {
+	//AUTHENTICATION & COMMAND ENDPOINT
+	adminChain, err := com.NewChain(core.config.ADMIN.middlewares()...)
+	if err != nil {
+		return nil, err
+	}
 
-	core.configAndServe()
+	//ONLY MODULES WITH A CERTIFICATE FROM INTERNAL CA, UNIX SOCKETS RELY ON FILE PERMISSIONS
+	if core.ca != nil && core.controlProtocol() != com.Unix {
+		adminChain = append(com.Chain{core.peerMiddleware()}, adminChain...)
+	}
+	adminRouter := core.adminRouter()
+	adminRouter.Handler("/connect", adminChain.Then(core.connect()), nil, nil)
+	adminRouter.Handler("/cmd", adminChain.Then(core.command()), nil, nil)
+	return adminRouter, nil
 }
 
-func (core *Core) configAndServe() {
-	path := ""
+func (core *Core) configAndServe(adminRouter *com.Router) {
 	server := core.config.SERVER
+	listeners := server.listeners()
 
-	if len(server.PATH) > 0 {
-		path = server.PATH[0].FROM
+	//SETUP AUTOMATIC CERTIFICATES
+	if server.ACME.ENABLED {
+		var err error
+		core.acme, err = core.newACMEManager()
+		if err != nil {
+			log.Fatalln("GO-WOXY Core - Error creating ACME manager : ", err)
+		}
 	}
-	log.Println("GO-WOXY Core - Serving at " + server.PROTOCOL + "://" + server.ADDRESS + ":" + server.PORT + path)
+
+	//FIRST LISTENER IS SERVED BY THE MAIN SERVER
+	main := listeners[0]
+	log.Println("GO-WOXY Core - Serving at " + main.Name())
 
 	var s = HttpServer{
 		Server: http.Server{
-			Addr:         server.ADDRESS + ":" + server.PORT + path,
-			Handler:      core.router,
+			Addr:         main.addr(),
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
 		},
 		shutdownReq: make(chan bool),
 	}
 
-	var listener net.Listener
-	var err error
+	listener, servers, err := core.listen(main, &s.Server)
+	if err != nil {
+		log.Fatal("GO-WOXY Core - ", err)
+	}
+	s.Servers = append(s.Servers, servers...)
 
-	//CHECK FOR CERTIFICATE TO TRY TLS CONFIG
-	if server.CERT != "" && server.CERT_KEY != "" {
-		tlsConfig, err := core.getTLSConfig()
+	for _, lc := range listeners[1:] {
+		servers, err := core.serveListener(lc)
 		if err != nil {
-			log.Fatalln("GO-WOXY Core - Error creating tls config : ", err)
+			log.Fatal("GO-WOXY Core - ", err)
 		}
+		s.Servers = append(s.Servers, servers...)
+	}
 
-		listener, err = tls.Listen("tcp", server.ADDRESS+":"+server.PORT+path, tlsConfig)
+	streams, err := core.serveStreams()
+	if err != nil {
+		log.Fatal("GO-WOXY Core - ", err)
+	}
+	s.Servers = append(s.Servers, streams...)
 
-		if err != nil {
-			log.Fatal("GO-WOXY Core - ", err)
-		}
+	if core.serveACMEChallengesNeeded() {
+		s.Servers = append(s.Servers, core.serveACMEChallenges())
+	}
 
-	} else {
-		listener, err = net.Listen("tcp", server.ADDRESS+":"+server.PORT+path)
-		if err != nil {
-			log.Fatal("GO-WOXY Core -", err)
-		}
+	//SERVE CONTROL ENDPOINTS ON THEIR OWN LISTENER
+	if core.config.ADMIN.Enabled() {
+		s.Servers = append(s.Servers, core.serveAdmin(adminRouter))
 	}
 
 	core.SetServer(&s)
@@ -240,22 +366,20 @@ func (core *Core) configAndServe() {
 		done <- true
 	}()
 
+	//RELOAD CERTIFICATES ON CHANGE
+	stopWatch := make(chan bool)
+	for _, certs := range core.certs {
+		go certs.watch(stopWatch)
+	}
+
 	//wait shutdown
 	s.WaitShutdown()
+	close(stopWatch)
 
 	<-done
 	log.Printf("GO-WOXY Core - Stopped")
 }
 
-func (core *Core) getTLSConfig() (*tls.Config, error) {
-	server := core.config.SERVER
-	cer, err := tls.LoadX509KeyPair(server.CERT, server.CERT_KEY)
-	if err != nil {
-		return &tls.Config{}, err
-	}
-	return &tls.Config{Certificates: []tls.Certificate{cer}}, nil
-}
-
 func (core *Core) loadModules() {
 	//INIT MODULE DIRECTORY
 	wd, err := os.Getwd()
@@ -302,6 +426,10 @@ func (core *Core) startModules() {
 			//ADD IT TO SUPERVISOR IF SUPERVISED
 			if m.EXE.SUPERVISED {
 				core.s.Add(m.NAME)
+			} else if m.streamType() != "" {
+				//STREAM MODULES NEVER CONNECT, ONLINE ONCE STARTED WITHOUT SUPERVISOR PROBING THEM
+				m.STATE = com.Online
+				core.syncRouteState(&m)
 			}
 
 			//SAVE CHANGES
@@ -322,6 +450,10 @@ func (core *Core) init() {
 
 	core.config.generateSecret()
 
+	if core.config.MTLS.ENABLED {
+		core.setupMTLS()
+	}
+
 	if len(core.config.ACCESSLOGFILE) == 0 {
 		core.config.ACCESSLOGFILE = "access.log"
 	}
@@ -333,6 +465,39 @@ func (core *Core) init() {
 	router := com.NewRouter(com.Error404()) //Custom Http Router
 	router.Middlewares = append(router.Middlewares, core.logMiddleware())
 
+	trustedProxies, err := com.ParseCIDRs(core.config.SERVER.TRUSTED_PROXIES)
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error reading trusted proxies : ", err)
+	}
+	router.TrustedProxies = trustedProxies
+
+	//ERROR PAGES OF RESOURCE DIRECTORY REPLACING EMBEDDED ONES
+	pagesDir := filepath.Join(core.config.RESOURCEDIR, "html")
+	if _, err := os.Stat(pagesDir); err == nil {
+		pages, err := com.LoadErrorPages(pagesDir)
+		if err != nil {
+			log.Fatalln("GO-WOXY Core - Error loading error pages : ", err)
+		}
+		com.SetErrorPages(pages)
+	}
+
+	//REDIRECTS ANSWERED BY ROUTER
+	for _, rule := range core.config.REDIRECTS {
+		if err := router.Redirect(rule); err != nil {
+			log.Fatalln("GO-WOXY Core - Error reading redirect", rule.FROM, ":", err)
+		}
+	}
+	if err := router.SetCanonical(core.config.SERVER.CANONICAL); err != nil {
+		log.Fatalln("GO-WOXY Core - Error reading canonical config : ", err)
+	}
+
+	//WHOLE INSTANCE UNDER MAINTENANCE, ADMIN ENDPOINTS EXCEPTED
+	maintenance, err := com.NewMaintenance(core.config.MAINTENANCE)
+	if err != nil {
+		log.Fatalln("GO-WOXY Core - Error reading maintenance config : ", err)
+	}
+	router.SetMaintenance(maintenance)
+
 	//Setup CommandProcessor
 	cp := CommandProcessorImpl{}
 	cp.Init()
@@ -371,13 +536,12 @@ func (core *Core) initLogs() {
 	coreLogger.SetFormatter(&logrus.TextFormatter{})
 	core.loggers["core"] = &coreLogger
 
-	accessLogger := coreLogger
-	accessLogger.SetOutput(accessLogFile)
+	accessLogger := logrus.Logger{Out: accessLogFile, Formatter: coreLogger.Formatter, Hooks: coreLogger.Hooks, Level: coreLogger.Level}
 	core.loggers["access"] = &accessLogger
 
 	//CREATE CUSTOM MODULE LOGGERS
 	for _, m := range core.modulesList {
-		logger := accessLogger
+		logger := logrus.Logger{Formatter: accessLogger.Formatter, Hooks: accessLogger.Hooks, Level: accessLogger.Level}
 		var logFile *os.File = accessLogFile
 		if m.LOG.IsEnabled() && m.LOG.Path != "default" {
 			path := m.LOG.Path + m.LOG.File
@@ -418,7 +582,7 @@ func (core *Core) logMiddleware() com.MiddlewareFunc {
 				}
 			}
 			logger.WithFields(logrus.Fields{
-				"from":    ctx.RemoteAddr,
+				"from":    ctx.ClientIP,
 				"request": requestLog,
 				"to":      routedToLog,
 			}).Info("routed")
@@ -512,7 +676,7 @@ func (core *Core) connect() com.HandlerFunc {
 			log.Println("GO-WOXY Core - Module connecting with name '" + modC.NAME + "'")
 
 			//GET THE REMOTE HOST ADDRESS IF HOST IS EMPTY
-			if !modC.EXE.REMOTE {
+			if !modC.EXE.REMOTE && modC.BINDING.PROTOCOL != com.Unix {
 				modC.BINDING.ADDRESS = strings.Split(ctx.Host, ":")[0]
 			}
 
@@ -547,7 +711,7 @@ func (core *Core) command() com.HandlerFunc {
 	return com.HandlerFunc(func(ctx *com.Context) {
 		t, b := com.GetCustomRequestType(ctx.Request)
 
-		from := ctx.Request.RemoteAddr
+		from := ctx.ClientIP
 		response := ""
 		action := ""
 
@@ -601,9 +765,15 @@ func (core *Core) command() com.HandlerFunc {
 	})
 }
 
+// Shutdowner - Server gracefully shut down with the main one
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
 // HttpServer -
 type HttpServer struct {
 	http.Server
+	Servers     []Shutdowner
 	shutdownReq chan bool
 	reqCount    uint32
 }
@@ -643,4 +813,11 @@ func (s *HttpServer) WaitShutdown() {
 	if err != nil {
 		log.Printf("Shutdown request error: %v", err)
 	}
+
+	//shutdown admin and challenge servers
+	for _, server := range s.Servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown request error: %v", err)
+		}
+	}
 }
@@ -0,0 +1,380 @@
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+/*ListenerConfig - Address the server listens on, with its own TLS settings */
+type ListenerConfig struct {
+	ADDRESS        string
+	CERT           string
+	CERT_KEY       string
+	CERTIFICATES   []CertificateConfig
+	H2C            bool
+	HTTP2          *bool
+	HTTP3          bool
+	PORT           string
+	PROTOCOL       string
+	PROXY_PROTOCOL bool
+	REDIRECT       bool
+	REDIRECT_PORT  string
+	SOCKET_MODE    string
+	TLS            *TLSConfig
+}
+
+// TLSEnabled - Listener is served over TLS
+func (lc *ListenerConfig) TLSEnabled() bool {
+	return lc.PROTOCOL == "https"
+}
+
+// HTTP2Enabled - HTTP/2 negotiated with ALPN, enabled by default over TLS
+func (lc *ListenerConfig) HTTP2Enabled() bool {
+	return lc.TLSEnabled() && (lc.HTTP2 == nil || *lc.HTTP2)
+}
+
+// protocols - Default ALPN protocols
+func (lc *ListenerConfig) protocols() []string {
+	if lc.HTTP2Enabled() {
+		return []string{"h2", "http/1.1"}
+	}
+	return []string{"http/1.1"}
+}
+
+// Name - Listener URL, socket path for unix
+func (lc *ListenerConfig) Name() string {
+	if lc.PROTOCOL == com.Unix {
+		return lc.PROTOCOL + "://" + lc.ADDRESS
+	}
+	return lc.PROTOCOL + "://" + lc.ADDRESS + ":" + lc.PORT
+}
+
+// addr - Listening address, without server path
+func (lc *ListenerConfig) addr() string {
+	if lc.PROTOCOL == com.Unix {
+		return lc.ADDRESS
+	}
+	return lc.ADDRESS + ":" + lc.PORT
+}
+
+// listeners - Configured listeners, server address being the only one without list
+func (sc *ServerConfig) listeners() []ListenerConfig {
+	if len(sc.LISTENERS) > 0 {
+		return sc.LISTENERS
+	}
+
+	protocol := sc.PROTOCOL
+	if protocol != com.Unix {
+		protocol = "http"
+		if sc.TLSEnabled() {
+			protocol = "https"
+		}
+	}
+	return []ListenerConfig{{
+		ADDRESS:        sc.ADDRESS,
+		CERT:           sc.CERT,
+		CERT_KEY:       sc.CERT_KEY,
+		CERTIFICATES:   sc.CERTIFICATES,
+		PORT:           sc.PORT,
+		PROTOCOL:       protocol,
+		PROXY_PROTOCOL: sc.PROXY_PROTOCOL,
+		SOCKET_MODE:    sc.SOCKET_MODE,
+	}}
+}
+
+// primary - First listener serving routes
+func (sc *ServerConfig) primary() ListenerConfig {
+	listeners := sc.listeners()
+	for _, lc := range listeners {
+		if !lc.REDIRECT {
+			return lc
+		}
+	}
+	return listeners[0]
+}
+
+// certificates - Listener certificates, server ones if none
+func (lc *ListenerConfig) certificates(sc *ServerConfig) []CertificateConfig {
+	var certs []CertificateConfig
+	if lc.CERT != "" && lc.CERT_KEY != "" {
+		certs = append(certs, CertificateConfig{CERT: lc.CERT, CERT_KEY: lc.CERT_KEY})
+	}
+	certs = append(certs, lc.CERTIFICATES...)
+	if len(certs) == 0 {
+		return sc.certificates()
+	}
+	return certs
+}
+
+func (c *Config) checkListeners() {
+	httpsPort := ""
+	for i := range c.SERVER.LISTENERS {
+		lc := &c.SERVER.LISTENERS[i]
+		if lc.PROTOCOL == "" {
+			lc.PROTOCOL = "http"
+		}
+		if lc.ADDRESS == "" {
+			lc.ADDRESS = c.SERVER.ADDRESS
+		}
+		if lc.PORT == "" {
+			lc.PORT = "80"
+			if lc.TLSEnabled() {
+				lc.PORT = "443"
+			}
+		}
+		if lc.TLSEnabled() && httpsPort == "" {
+			httpsPort = lc.PORT
+		}
+
+		if lc.HTTP3 && !lc.TLSEnabled() {
+			log.Fatalln("GO-WOXY Core - http3 requires https on listener " + lc.Name())
+		}
+		if lc.H2C && lc.TLSEnabled() {
+			log.Fatalln("GO-WOXY Core - h2c is only available on cleartext listener " + lc.Name())
+		}
+	}
+
+	for i := range c.SERVER.LISTENERS {
+		lc := &c.SERVER.LISTENERS[i]
+		if lc.REDIRECT && lc.REDIRECT_PORT == "" {
+			lc.REDIRECT_PORT = httpsPort
+		}
+	}
+}
+
+// listen - Open listener for server, reading PROXY protocol, terminating TLS and setting up HTTP/2 and HTTP/3
+// Returned servers are the extra ones to shut down with server
+func (core *Core) listen(lc ListenerConfig, srv *http.Server) (net.Listener, []Shutdowner, error) {
+	var servers []Shutdowner
+	srv.Handler = core.handler(lc)
+
+	mode, err := com.ParseFileMode(lc.SOCKET_MODE)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := com.Listen(lc.PROTOCOL, lc.ADDRESS, lc.PORT, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//READ REAL CLIENT ADDRESS FROM TRUSTED PROXIES
+	if lc.PROXY_PROTOCOL {
+		listener = &proxyProtoListener{Listener: listener, trusted: core.router.TrustedProxies}
+	}
+
+	//HTTP/2 WITHOUT TLS FOR INTERNAL LISTENERS
+	if lc.H2C {
+		srv.Handler = h2c.NewHandler(srv.Handler, &http2.Server{})
+	}
+
+	if !lc.TLSEnabled() {
+		return listener, servers, nil
+	}
+
+	tlsConfig, err := core.getTLSConfig(lc)
+	if err != nil {
+		listener.Close()
+		return nil, nil, err
+	}
+
+	if lc.HTTP2Enabled() {
+		if err := http2.ConfigureServer(srv, &http2.Server{}); err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+	} else {
+		//NON NIL EMPTY MAP DISABLES HTTP/2
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	//HTTP/3 OVER QUIC ON THE SAME PORT, ADVERTISED WITH ALT-SVC
+	if lc.HTTP3 {
+		h3, err := core.serveHTTP3(lc, listener.Addr().String(), srv.Handler, tlsConfig)
+		if err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+		srv.Handler = altSvc(h3, srv.Handler)
+		servers = append(servers, h3)
+	}
+
+	return tls.NewListener(listener, tlsConfig), servers, nil
+}
+
+// serveHTTP3 - Serve handler over QUIC on udp address
+func (core *Core) serveHTTP3(lc ListenerConfig, addr string, handler http.Handler, tlsConfig *tls.Config) (*http3.Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("GO-WOXY Core - Serving HTTP/3 at udp://" + conn.LocalAddr().String())
+
+	h3 := &http3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+	}
+	go func() {
+		if err := h3.Serve(conn); err != nil && err != http.ErrServerClosed {
+			log.Printf("GO-WOXY Core - %s HTTP/3 %v", lc.Name(), err)
+		}
+	}()
+	return h3, nil
+}
+
+// altSvc - Advertise HTTP/3 on TCP responses
+func altSvc(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h3.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handler - Routes or redirection to https
+func (core *Core) handler(lc ListenerConfig) http.Handler {
+	if lc.REDIRECT {
+		return core.redirectHandler(lc.REDIRECT_PORT)
+	}
+	return core.router
+}
+
+// redirectHandler - Permanent redirection to https, ACME challenges being answered
+func (core *Core) redirectHandler(port string) http.Handler {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+
+		//308 KEEPS METHOD AND BODY
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+
+	if core.acme != nil {
+		return core.acme.HTTPHandler(redirect)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			http.NotFound(w, r)
+			return
+		}
+		redirect(w, r)
+	})
+}
+
+// serveListener - Serve extra listener, returned servers being shut down with the main server
+func (core *Core) serveListener(lc ListenerConfig) ([]Shutdowner, error) {
+	log.Println("GO-WOXY Core - Serving at " + lc.Name())
+
+	s := &http.Server{
+		Addr:         lc.addr(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	listener, servers, err := core.listen(lc, s)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := s.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("GO-WOXY Core - %s %v", lc.Name(), err)
+		}
+	}()
+	return append(servers, s), nil
+}
+
+// serveACMEChallengesNeeded - HTTP-01 challenges need their own listener when no redirect listener answers them
+func (core *Core) serveACMEChallengesNeeded() bool {
+	server := core.config.SERVER
+	if core.acme == nil || !server.ACME.HTTPChallenge() {
+		return false
+	}
+	for _, lc := range server.listeners() {
+		if lc.REDIRECT && lc.PORT == server.ACME.HTTP_PORT {
+			return false
+		}
+	}
+	return true
+}
+
+func (core *Core) getTLSConfig(lc ListenerConfig) (*tls.Config, error) {
+	certs, err := newCertStore(lc.certificates(&core.config.SERVER))
+	if err != nil {
+		return &tls.Config{}, err
+	}
+	if len(certs.certs) == 0 && core.acme == nil {
+		return &tls.Config{}, errors.New("no certificate for " + lc.Name())
+	}
+	certs.listener = lc.Name()
+	core.certs = append(core.certs, certs)
+
+	policy := lc.TLS
+	if policy == nil {
+		policy = &core.config.SERVER.TLS
+	}
+
+	cfg := &tls.Config{GetCertificate: core.certificateGetter(certs)}
+	if err := policy.apply(cfg); err != nil {
+		return &tls.Config{}, err
+	}
+
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = lc.protocols()
+	}
+
+	//TLS-ALPN-01 CHALLENGE
+	if core.acme != nil && core.config.SERVER.ACME.ALPNChallenge() {
+		cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+	}
+	return cfg, nil
+}
+
+// certificateGetter - Select certificate from SNI : static certificates, ACME, then default certificate
+func (core *Core) certificateGetter(certs *certStore) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if core.acme != nil && core.config.SERVER.ACME.ALPNChallenge() {
+			for _, proto := range hello.SupportedProtos {
+				if proto == acme.ALPNProto {
+					return core.acme.GetCertificate(hello)
+				}
+			}
+		}
+
+		if cert := certs.match(hello.ServerName); cert != nil {
+			return cert, nil
+		}
+
+		if core.acme != nil {
+			cert, err := core.acme.GetCertificate(hello)
+			if err == nil || certs.fallback() == nil {
+				return cert, err
+			}
+		}
+
+		if cert := certs.fallback(); cert != nil {
+			return cert, nil
+		}
+		return nil, errors.New("no certificate for " + hello.ServerName)
+	}
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+)
+
+// newTestCore - Core answering with the request protocol, and a certificate for 127.0.0.1
+func newTestCore(t *testing.T) (*Core, CertificateConfig, *x509.CertPool) {
+	dir := t.TempDir()
+	ca, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := ca.issue("localhost", []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := CertificateConfig{CERT: filepath.Join(dir, "cert.pem"), CERT_KEY: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(cert.CERT, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cert.CERT_KEY, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.certPEM)
+
+	core := &Core{
+		config: &Config{},
+		router: com.NewRouter(func(ctx *com.Context) {
+			ctx.Text(http.StatusOK, ctx.Proto)
+		}),
+	}
+	return core, cert, roots
+}
+
+// serveTest - Serve listener until test end, returning its address
+func serveTest(t *testing.T, core *Core, lc ListenerConfig) string {
+	srv := &http.Server{}
+	listener, servers, err := core.listen(lc, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		for _, s := range servers {
+			s.Shutdown(ctx)
+		}
+	})
+	return listener.Addr().String()
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, strings.TrimSpace(string(body))
+}
+
+func TestListenerProtocols(t *testing.T) {
+	disabled := false
+
+	tests := []struct {
+		name  string
+		http2 *bool
+		want  string
+	}{
+		{"http2 by default", nil, "HTTP/2.0"},
+		{"http2 disabled", &disabled, "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, cert, roots := newTestCore(t)
+			addr := serveTest(t, core, ListenerConfig{
+				ADDRESS:  "127.0.0.1",
+				PORT:     "0",
+				PROTOCOL: "https",
+				CERT:     cert.CERT,
+				CERT_KEY: cert.CERT_KEY,
+				HTTP2:    tt.http2,
+			})
+
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots},
+				ForceAttemptHTTP2: true,
+			}}
+			resp, body := get(t, client, "https://"+addr+"/")
+			if resp.Proto != tt.want || body != tt.want {
+				t.Errorf("got %s (handler saw %s), want %s", resp.Proto, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenerH2C(t *testing.T) {
+	core, _, _ := newTestCore(t)
+	addr := serveTest(t, core, ListenerConfig{ADDRESS: "127.0.0.1", PORT: "0", PROTOCOL: "http", H2C: true})
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, body := get(t, client, "http://"+addr+"/")
+	if resp.Proto != "HTTP/2.0" || body != "HTTP/2.0" {
+		t.Errorf("got %s (handler saw %s), want HTTP/2.0", resp.Proto, body)
+	}
+
+	//HTTP/1.1 CLIENTS STILL SERVED
+	resp, body = get(t, http.DefaultClient, "http://"+addr+"/")
+	if resp.Proto != "HTTP/1.1" || body != "HTTP/1.1" {
+		t.Errorf("got %s (handler saw %s), want HTTP/1.1", resp.Proto, body)
+	}
+}
+
+func TestListenerHTTP3(t *testing.T) {
+	core, cert, roots := newTestCore(t)
+	addr := serveTest(t, core, ListenerConfig{
+		ADDRESS:  "127.0.0.1",
+		PORT:     "0",
+		PROTOCOL: "https",
+		CERT:     cert.CERT,
+		CERT_KEY: cert.CERT_KEY,
+		HTTP3:    true,
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	//ALT-SVC ADVERTISED OVER TCP
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, _ := get(t, client, "https://"+addr+"/")
+	if want := `h3=":` + port + `"; ma=2592000`; resp.Header.Get("Alt-Svc") != want {
+		t.Errorf("Alt-Svc = %q, want %q", resp.Header.Get("Alt-Svc"), want)
+	}
+
+	h3 := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	defer h3.Close()
+	resp, body := get(t, &http.Client{Transport: h3, Timeout: 5 * time.Second}, "https://"+addr+"/")
+	if resp.Proto != "HTTP/3.0" || body != "HTTP/3.0" {
+		t.Errorf("got %s (handler saw %s), want HTTP/3.0", resp.Proto, body)
+	}
+}
+
+func TestCheckListeners(t *testing.T) {
+	c := Config{}
+	c.SERVER.ADDRESS = "0.0.0.0"
+	c.SERVER.LISTENERS = []ListenerConfig{
+		{REDIRECT: true},
+		{PROTOCOL: "https", PORT: "8443"},
+		{PROTOCOL: "http", PORT: "8080", H2C: true},
+	}
+	c.checkListeners()
+
+	l := c.SERVER.LISTENERS
+	if l[0].PORT != "80" || l[0].ADDRESS != "0.0.0.0" || l[0].REDIRECT_PORT != "8443" {
+		t.Errorf("redirect listener defaults : %+v", l[0])
+	}
+	if !l[1].HTTP2Enabled() || l[2].HTTP2Enabled() {
+		t.Errorf("http2 is only enabled by default over tls")
+	}
+	if p := c.SERVER.primary(); p.PORT != "8443" {
+		t.Errorf("primary listener = %s, want port 8443", p.Name())
+	}
+}
+
+func TestACMEALPNProtocol(t *testing.T) {
+	tests := []struct {
+		challenges []string
+		want       bool
+	}{
+		{[]string{"http-01"}, false},
+		{[]string{"tls-alpn-01"}, true},
+		{[]string{"http-01", "tls-alpn-01"}, true},
+	}
+	for _, tt := range tests {
+		core, cert, _ := newTestCore(t)
+		core.acme = &autocert.Manager{}
+		core.config.SERVER.ACME.CHALLENGES = tt.challenges
+		cfg, err := core.getTLSConfig(ListenerConfig{PROTOCOL: "https", CERT: cert.CERT, CERT_KEY: cert.CERT_KEY})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, p := range cfg.NextProtos {
+			found = found || p == acme.ALPNProto
+		}
+		if found != tt.want {
+			t.Errorf("challenges %v : %s advertised = %v, want %v", tt.challenges, acme.ALPNProto, found, tt.want)
+		}
+	}
+}
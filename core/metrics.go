@@ -0,0 +1,39 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// Metrics - GO-WOXY runtime metrics
+type Metrics struct {
+	CONNECTIONS []ConnectionStats
+	RATE_LIMITS []com.RateLimiterStats
+}
+
+// ConnectionStats - Upgraded (WebSocket, h2c) and TCP stream connections proxied to a module
+type ConnectionStats struct {
+	MODULE string
+	ACTIVE int
+	TOTAL  uint64
+}
+
+// GetMetrics - Collect current metrics
+func (core *Core) GetMetrics() Metrics {
+	return Metrics{
+		CONNECTIONS: core.connectionsStats(),
+		RATE_LIMITS: com.RateLimitersStats(),
+	}
+}
+
+func (core *Core) connectionsStats() []ConnectionStats {
+	core.routesMux.Lock()
+	defer core.routesMux.Unlock()
+	stats := make([]ConnectionStats, 0, len(core.routeConfigs))
+	for name, rc := range core.routeConfigs {
+		stats = append(stats, ConnectionStats{MODULE: name, ACTIVE: rc.Upgrades().Active(), TOTAL: rc.Upgrades().Total()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].MODULE < stats[j].MODULE })
+	return stats
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/Wariie/go-woxy/com"
@@ -44,6 +45,16 @@ func (mc *ModuleConfig) Download(moduleDir string) {
 	}
 }
 
+// downloaded - Module is cloned from its repository by go-woxy
+func (mc *ModuleConfig) downloaded() bool {
+	return !mc.EXE.REMOTE && (strings.Contains(mc.EXE.SRC, "http") || strings.Contains(mc.EXE.SRC, "git@"))
+}
+
+// issuedCertificate - Module gets a certificate from internal CA, local modules not cloned by go-woxy keeping plain http
+func (mc *ModuleConfig) issuedCertificate() bool {
+	return mc.EXE.REMOTE || mc.downloaded()
+}
+
 // GetLog - GetLog from Module
 func (mc *ModuleConfig) GetLog() string {
 	b, err := os.ReadFile(mc.EXE.BIN + "/log.log")
@@ -85,6 +96,11 @@ func (mc *ModuleConfig) Start() {
 
 	cmd := exec.Command(platformParam[0], platformParam[1:]...)
 	cmd.Dir = mc.EXE.BIN
+	cmd.Env = append(os.Environ(),
+		com.EnvHubAddress+"="+string(mc.hub.IP),
+		com.EnvHubPort+"="+string(mc.hub.Port),
+		com.EnvHubProtocol+"="+string(mc.hub.Protocol),
+	)
 	cmd.Start()
 	mc.EXE.LastPing = time.Now()
 	mc.pid = cmd.Process.Pid
@@ -109,24 +125,34 @@ func (mc *ModuleConfig) generateAPIKey() {
 }
 
 func (mc *ModuleConfig) getRouteConfig() *com.RouteConfig {
-	return &com.RouteConfig{BINDING: mc.BINDING, STATE: mc.STATE, NAME: mc.NAME, TYPES: mc.TYPES}
+	return &com.RouteConfig{BINDING: mc.BINDING, STATE: mc.STATE, ERRORS: mc.ERRORS, ERROR_PAGES: mc.ERROR_PAGES, MAINTENANCE: mc.MAINTENANCE, NAME: mc.NAME, TYPES: mc.TYPES, IDLE_TIMEOUT: mc.IDLE_TIMEOUT, PRESERVE_HOST: mc.PRESERVE_HOST, PROXY: mc.PROXY}
 }
 
 /*ModuleConfig - Module configuration */
 type ModuleConfig struct {
-	API_KEY      string
-	AUTH         ModuleAuthConfig
-	BINDING      com.ServerConfig
-	COMMANDS     []string
-	EXE          ModuleExecConfig
-	NAME         string
-	pid          int
-	PK           string
-	RESOURCEPATH string
-	LOG          ModuleLogConfig
-	STATE        com.ModuleState
-	TYPES        string
-	VERSION      int
+	API_KEY       string
+	AUTH          ModuleAuthConfig
+	BINDING       com.ServerConfig
+	COMMANDS      []string
+	ERRORS        com.ErrorPolicy
+	ERROR_PAGES   string
+	EXE           ModuleExecConfig
+	HEADERS       com.HeaderRules
+	hub           com.Server
+	IDLE_TIMEOUT  time.Duration
+	NAME          string
+	pid           int
+	PK            string
+	PRESERVE_HOST bool
+	PROXY         com.ProxyConfig
+	RESOURCEPATH  string
+	LOG           ModuleLogConfig
+	MAINTENANCE   com.MaintenanceConfig
+	MIDDLEWARES   []com.MiddlewareConfig
+	STATE         com.ModuleState
+	STREAM        StreamConfig
+	TYPES         string
+	VERSION       int
 }
 
 /*ModuleLogConfig - Module Logging Configuration */
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+var proxyProtoV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtoListener - Listener reading PROXY protocol headers (v1 and v2) sent by trusted proxies
+type proxyProtoListener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+// Accept - Wrap accepted connection, header is read lazily from the connection goroutine
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtoConn{Conn: conn, trusted: l.trusted}, nil
+}
+
+type proxyProtoConn struct {
+	net.Conn
+	trusted []*net.IPNet
+	once    sync.Once
+	reader  *bufio.Reader
+	remote  net.Addr
+	err     error
+}
+
+func (c *proxyProtoConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remote
+}
+
+// readHeader - Replace remote address with the one given by a trusted proxy
+func (c *proxyProtoConn) readHeader() {
+	c.remote = c.Conn.RemoteAddr()
+	c.reader = bufio.NewReader(c.Conn)
+
+	tcpAddr, ok := c.remote.(*net.TCPAddr)
+	if !ok || !com.ContainsIP(c.trusted, tcpAddr.IP) {
+		return
+	}
+
+	c.Conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	var addr net.Addr
+	if b, err := c.reader.Peek(len(proxyProtoV2Signature)); err == nil && bytes.Equal(b, proxyProtoV2Signature) {
+		addr, c.err = c.readV2()
+	} else if b, err := c.reader.Peek(6); err == nil && string(b) == "PROXY " {
+		addr, c.err = c.readV1()
+	}
+
+	if c.err != nil {
+		c.Conn.Close()
+	} else if addr != nil {
+		c.remote = addr
+	}
+}
+
+// readV1 - PROXY TCP4|TCP6|UNKNOWN src dst srcport dstport\r\n
+func (c *proxyProtoConn) readV1() (net.Addr, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("invalid PROXY v1 header")
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.New("invalid PROXY v1 header")
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil {
+		return nil, errors.New("invalid PROXY v1 source address")
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readV2 - Binary header, only TCP over IPv4 and IPv6 sources are used
+func (c *proxyProtoConn) readV2() (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, errors.New("invalid PROXY v2 version")
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return nil, err
+	}
+
+	//LOCAL COMMAND : KEEP CONNECTION ADDRESS
+	switch header[12] & 0x0F {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("invalid PROXY v2 command")
+	}
+
+	switch header[13] {
+	case 0x11: //TCP over IPv4
+		if len(body) < 12 {
+			return nil, errors.New("invalid PROXY v2 address")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x21: //TCP over IPv6
+		if len(body) < 36 {
+			return nil, errors.New("invalid PROXY v2 address")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// proxyProtoDial - Send header then payload through a PROXY protocol listener, return remote address and payload read
+func proxyProtoDial(t *testing.T, trusted []string, header []byte) (string, string, error) {
+	t.Helper()
+	cidrs, err := com.ParseCIDRs(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	pl := &proxyProtoListener{Listener: ln, trusted: cidrs}
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go func() {
+		client.Write(append(append([]byte{}, header...), "payload"...))
+		client.(*net.TCPConn).CloseWrite()
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b := make([]byte, 7)
+	_, err = io.ReadFull(conn, b)
+	return conn.RemoteAddr().String(), string(b), err
+}
+
+// proxyV2 - PROXY v2 header of version, command, family and address block
+func proxyV2(verCmd, family byte, addr []byte) []byte {
+	h := append(append([]byte{}, proxyProtoV2Signature...), verCmd, family, 0, 0)
+	binary.BigEndian.PutUint16(h[14:16], uint16(len(addr)))
+	return append(h, addr...)
+}
+
+func TestProxyProtoV1(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"tcp4", "PROXY TCP4 198.51.100.2 10.0.0.1 4321 443\r\n", "198.51.100.2:4321", false},
+		{"tcp6", "PROXY TCP6 2001:db8::2 2001:db8::1 4321 443\r\n", "[2001:db8::2]:4321", false},
+		{"unknown keeps address", "PROXY UNKNOWN\r\n", "127.0.0.1", false},
+		{"no header", "", "127.0.0.1", false},
+		{"missing crlf", "PROXY TCP4 198.51.100.2 10.0.0.1 4321 443\n", "", true},
+		{"bad address", "PROXY TCP4 not-an-ip 10.0.0.1 4321 443\r\n", "", true},
+		{"bad protocol", "PROXY UDP4 198.51.100.2 10.0.0.1 4321 443\r\n", "", true},
+		{"missing fields", "PROXY TCP4 198.51.100.2\r\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, payload, err := proxyProtoDial(t, []string{"127.0.0.1"}, []byte(tt.header))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("header accepted, remote %s", remote)
+				}
+				return
+			}
+			if err != nil || payload != "payload" {
+				t.Fatalf("payload %q, err %v", payload, err)
+			}
+			if host, _, _ := net.SplitHostPort(remote); remote != tt.want && host != tt.want {
+				t.Errorf("remote = %s, want %s", remote, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyProtoV2(t *testing.T) {
+	ipv4 := []byte{198, 51, 100, 2, 10, 0, 0, 1, 0x10, 0xE1, 0x01, 0xBB}
+	ipv6 := append(append(net.ParseIP("2001:db8::2").To16(), net.ParseIP("2001:db8::1").To16()...), 0x10, 0xE1, 0x01, 0xBB)
+	tests := []struct {
+		name    string
+		header  []byte
+		want    string
+		wantErr bool
+	}{
+		{"tcp4", proxyV2(0x21, 0x11, ipv4), "198.51.100.2:4321", false},
+		{"tcp6", proxyV2(0x21, 0x21, ipv6), "[2001:db8::2]:4321", false},
+		{"tcp4 with tlvs", proxyV2(0x21, 0x11, append(ipv4, 0x04, 0x00, 0x01, 0xFF)), "198.51.100.2:4321", false},
+		{"local keeps address", proxyV2(0x20, 0x00, nil), "127.0.0.1", false},
+		{"unspecified family keeps address", proxyV2(0x21, 0x00, nil), "127.0.0.1", false},
+		{"bad version", proxyV2(0x11, 0x11, ipv4), "", true},
+		{"bad command", proxyV2(0x22, 0x11, ipv4), "", true},
+		{"short ipv4 address", proxyV2(0x21, 0x11, ipv4[:8]), "", true},
+		{"short ipv6 address", proxyV2(0x21, 0x21, ipv6[:20]), "", true},
+		{"truncated address", proxyV2(0x21, 0x11, ipv4)[:20], "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, payload, err := proxyProtoDial(t, []string{"127.0.0.1"}, tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("header accepted, remote %s", remote)
+				}
+				return
+			}
+			if err != nil || payload != "payload" {
+				t.Fatalf("payload %q, err %v", payload, err)
+			}
+			if host, _, _ := net.SplitHostPort(remote); remote != tt.want && host != tt.want {
+				t.Errorf("remote = %s, want %s", remote, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyProtoUntrustedPeer(t *testing.T) {
+	header := []byte("PROXY TCP4 198.51.100.2 10.0.0.1 4321 443\r\n")
+	_, payload, err := proxyProtoDial(t, []string{"10.0.0.0/8"}, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//HEADER OF UNTRUSTED PEER LEFT IN STREAM, NEVER CHANGING ITS ADDRESS
+	if !bytes.HasPrefix(header, []byte(payload)) {
+		t.Errorf("payload = %q, want header bytes", payload)
+	}
+}
@@ -0,0 +1,477 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Stream module types
+const (
+	StreamTCP = "tcp"
+	StreamUDP = "udp"
+)
+
+// DefaultMaxUDPSessions - Client addresses relayed at once to an udp module without max_sessions
+const DefaultMaxUDPSessions = 1024
+
+var errServerNameRead = errors.New("server name read")
+
+/*StreamConfig - Port a tcp or udp module is exposed on */
+type StreamConfig struct {
+	ADDRESS      string
+	CERT         string
+	CERT_KEY     string
+	MAX_SESSIONS int
+	PORT         string
+	SNI          []string
+	TLS          bool
+}
+
+// Name - Stream listener URL
+func (sc *StreamConfig) Name(network string) string {
+	return network + "://" + sc.ADDRESS + ":" + sc.PORT
+}
+
+// streamType - Module stream network, empty for http modules
+func (mc *ModuleConfig) streamType() string {
+	for _, t := range []string{StreamTCP, StreamUDP} {
+		if strings.Contains(mc.TYPES, t) {
+			return t
+		}
+	}
+	return ""
+}
+
+func (c *Config) checkStreams() {
+	names := make([]string, 0, len(c.MODULES))
+	for name := range c.MODULES {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sni := map[string]string{}
+	for _, name := range names {
+		m := c.MODULES[name]
+		network := m.streamType()
+		if network == "" {
+			continue
+		}
+
+		if m.STREAM.PORT == "" {
+			log.Fatalln("GO-WOXY Core - Module", name, ": stream port required for", network, "modules")
+		}
+		if m.STREAM.ADDRESS == "" {
+			m.STREAM.ADDRESS = c.SERVER.ADDRESS
+		}
+		if network == StreamUDP && (m.STREAM.TLS || len(m.STREAM.SNI) > 0) {
+			log.Fatalln("GO-WOXY Core - Module", name, ": tls and sni are only supported by tcp modules")
+		}
+		if network == StreamUDP && m.BINDING.PROTOCOL == com.Unix {
+			log.Fatalln("GO-WOXY Core - Module", name, ": udp modules can't be bound to unix sockets")
+		}
+		if m.MAINTENANCE.ENABLED {
+			log.Fatalln("GO-WOXY Core - Module", name, ": maintenance is not supported by", network, "modules")
+		}
+		if network == StreamUDP && m.STREAM.MAX_SESSIONS <= 0 {
+			m.STREAM.MAX_SESSIONS = DefaultMaxUDPSessions
+		}
+
+		//ONE MODULE PER SERVER NAME, ONE WITHOUT SNI PER PORT
+		hosts := m.STREAM.SNI
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for _, host := range hosts {
+			key := m.STREAM.Name(network) + " " + strings.ToLower(host)
+			if other, ok := sni[key]; ok {
+				log.Fatalln("GO-WOXY Core - Modules", other, "and", name, "share", m.STREAM.Name(network), "without distinct sni")
+			}
+			sni[key] = name
+		}
+		c.MODULES[name] = m
+	}
+}
+
+// streamRoute - Module reached through a stream listener
+type streamRoute struct {
+	rc  *com.RouteConfig
+	tls *tls.Config
+}
+
+// streamListener - TCP or UDP port shared by stream modules, TCP ones being selected by SNI
+type streamListener struct {
+	active      int64
+	name        string
+	network     string
+	routes      map[string]*streamRoute
+	fallback    *streamRoute
+	listener    net.Listener
+	packet      net.PacketConn
+	sessions    sync.Map
+	maxSessions int
+	full        bool
+}
+
+// serveStreams - Listen on ports of stream modules, returned servers being shut down with the main server
+func (core *Core) serveStreams() ([]Shutdowner, error) {
+	listeners := map[string]*streamListener{}
+	var order []string
+
+	core.mux.Lock()
+	modules := append([]ModuleConfig{}, core.modulesList...)
+	core.mux.Unlock()
+
+	for i := range modules {
+		mc := &modules[i]
+		network := mc.streamType()
+		if network == "" {
+			continue
+		}
+
+		name := mc.STREAM.Name(network)
+		sl, ok := listeners[name]
+		if !ok {
+			sl = &streamListener{name: name, network: network, routes: map[string]*streamRoute{}}
+			listeners[name] = sl
+			order = append(order, name)
+		}
+
+		route := &streamRoute{rc: core.routeConfig(mc)}
+		if mc.STREAM.TLS {
+			cfg, err := core.getTLSConfig(ListenerConfig{
+				ADDRESS:  mc.STREAM.ADDRESS,
+				CERT:     mc.STREAM.CERT,
+				CERT_KEY: mc.STREAM.CERT_KEY,
+				PORT:     mc.STREAM.PORT,
+				PROTOCOL: "https",
+			})
+			if err != nil {
+				return nil, err
+			}
+			//NO HTTP PROTOCOLS OVER RAW STREAMS
+			cfg.NextProtos = core.config.SERVER.TLS.ALPN
+			route.tls = cfg
+		}
+
+		if len(mc.STREAM.SNI) == 0 {
+			sl.fallback = route
+			sl.maxSessions = mc.STREAM.MAX_SESSIONS
+		}
+		for _, host := range mc.STREAM.SNI {
+			sl.routes[strings.ToLower(host)] = route
+		}
+	}
+
+	var servers []Shutdowner
+	for _, name := range order {
+		sl := listeners[name]
+		if err := sl.listen(); err != nil {
+			for _, s := range servers {
+				s.Shutdown(context.Background())
+			}
+			return nil, err
+		}
+		log.Println("GO-WOXY Core - Serving stream at " + sl.name)
+		servers = append(servers, sl)
+	}
+	return servers, nil
+}
+
+// listen - Open port then serve it
+func (sl *streamListener) listen() error {
+	addr := strings.TrimPrefix(sl.name, sl.network+"://")
+	var err error
+	if sl.network == StreamUDP {
+		sl.packet, err = net.ListenPacket("udp", addr)
+		if err == nil {
+			go sl.servePackets()
+		}
+		return err
+	}
+
+	sl.listener, err = net.Listen("tcp", addr)
+	if err == nil {
+		go sl.serve()
+	}
+	return err
+}
+
+// Shutdown - Stop listening and close proxied connections
+func (sl *streamListener) Shutdown(ctx context.Context) error {
+	var err error
+	if sl.listener != nil {
+		err = sl.listener.Close()
+	}
+	if sl.packet != nil {
+		err = sl.packet.Close()
+	}
+	for _, route := range sl.allRoutes() {
+		route.rc.Upgrades().CloseAll()
+	}
+	return err
+}
+
+func (sl *streamListener) allRoutes() []*streamRoute {
+	routes := []*streamRoute{}
+	if sl.fallback != nil {
+		routes = append(routes, sl.fallback)
+	}
+	for _, route := range sl.routes {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// sniRouting - Server name must be read before selecting module
+func (sl *streamListener) sniRouting() bool {
+	return len(sl.routes) > 0
+}
+
+// route - Module for server name, module without SNI otherwise
+func (sl *streamListener) route(serverName string) *streamRoute {
+	if route, ok := sl.routes[strings.ToLower(serverName)]; ok {
+		return route
+	}
+	return sl.fallback
+}
+
+func (sl *streamListener) serve() {
+	for {
+		conn, err := sl.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Println("GO-WOXY Core -", sl.name, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go sl.handle(conn)
+	}
+}
+
+// handle - Select module, terminate TLS if needed then tunnel connection to module
+func (sl *streamListener) handle(conn net.Conn) {
+	serverName := ""
+	if sl.sniRouting() {
+		var err error
+		serverName, conn, err = peekServerName(conn)
+		if err != nil {
+			log.Println("GO-WOXY Core -", sl.name, "- Error reading server name :", err)
+		}
+	}
+
+	route := sl.route(serverName)
+	if route == nil || route.rc.State() != com.Online {
+		conn.Close()
+		return
+	}
+
+	if route.tls != nil {
+		tc := tls.Server(conn, route.tls)
+		tc.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tc.Handshake(); err != nil {
+			log.Println("GO-WOXY Core -", sl.name, "- TLS handshake error :", err)
+			tc.Close()
+			return
+		}
+		tc.SetDeadline(time.Time{})
+		conn = tc
+	}
+
+	backend, err := dialStream(StreamTCP, route.rc.Binding())
+	if err != nil {
+		log.Println("GO-WOXY Core -", sl.name, "- Error reaching", route.rc.NAME, ":", err)
+		conn.Close()
+		return
+	}
+	route.rc.Tunnel(conn, backend)
+}
+
+// dialStream - Connection to module binding, unix socket or network address
+func dialStream(network string, binding com.ServerConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if binding.PROTOCOL == com.Unix {
+		return dialer.Dial("unix", binding.ADDRESS)
+	}
+	return dialer.Dial(network, net.JoinHostPort(binding.ADDRESS, binding.PORT))
+}
+
+// streamHealthy - Stream module accepts connections, udp ones being only checked to be running
+func (mc *ModuleConfig) streamHealthy() bool {
+	if mc.streamType() == StreamUDP {
+		running, err := process.PidExists(int32(mc.pid))
+		return mc.pid > 0 && err == nil && running
+	}
+	conn, err := dialStream(StreamTCP, mc.BINDING)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// peekServerName - Read server name from TLS ClientHello, returned connection replaying read bytes
+func peekServerName(conn net.Conn) (string, net.Conn, error) {
+	br := bufio.NewReader(conn)
+	replay := &replayConn{Conn: conn, r: br}
+
+	//NOT A TLS HANDSHAKE RECORD
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	if b, err := br.Peek(1); err != nil || b[0] != 0x16 {
+		return "", replay, err
+	}
+
+	var hello bytes.Buffer
+	serverName := ""
+	err := tls.Server(readOnlyConn{Conn: conn, r: io.TeeReader(br, &hello)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = info.ServerName
+			return nil, errServerNameRead
+		},
+	}).Handshake()
+
+	replay.r = io.MultiReader(&hello, br)
+	if errors.Is(err, errServerNameRead) {
+		err = nil
+	}
+	return serverName, replay, err
+}
+
+// readOnlyConn - Connection handshake can read from but not answer to
+type readOnlyConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c readOnlyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c readOnlyConn) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// replayConn - Connection giving back bytes read ahead
+type replayConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// udpSession - Client datagrams relayed to module through its own socket
+type udpSession struct {
+	backend net.Conn
+	untrack func()
+	last    time.Time
+	mux     sync.Mutex
+}
+
+func (s *udpSession) touch() {
+	s.mux.Lock()
+	s.last = time.Now()
+	s.mux.Unlock()
+}
+
+func (s *udpSession) idle() time.Duration {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return time.Since(s.last)
+}
+
+// servePackets - Relay datagrams, one module socket per client address
+func (sl *streamListener) servePackets() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, client, err := sl.packet.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		route := sl.fallback
+		key := client.String()
+		if route == nil || route.rc.State() != com.Online {
+			continue
+		}
+
+		s, ok := sl.sessions.Load(key)
+		if !ok {
+			//NEW CLIENTS DROPPED ONCE SESSIONS ARE FULL
+			if sl.maxSessions > 0 && atomic.LoadInt64(&sl.active) >= int64(sl.maxSessions) {
+				if !sl.full {
+					sl.full = true
+					log.Println("GO-WOXY Core -", sl.name, "- Max udp sessions reached, dropping new clients")
+				}
+				continue
+			}
+			sl.full = false
+
+			backend, err := dialStream(StreamUDP, route.rc.Binding())
+			if err != nil {
+				log.Println("GO-WOXY Core -", sl.name, "- Error reaching", route.rc.NAME, ":", err)
+				continue
+			}
+			//TRACKED WITH MODULE CONNECTIONS, CLOSED WHEN IT GOES OFFLINE
+			s = &udpSession{backend: backend, untrack: route.rc.Upgrades().Track(backend)}
+			sl.sessions.Store(key, s)
+			atomic.AddInt64(&sl.active, 1)
+			go sl.relay(route, key, client, s.(*udpSession))
+		}
+
+		session := s.(*udpSession)
+		session.touch()
+		session.backend.Write(buf[:n])
+	}
+}
+
+// relay - Send module datagrams back to client until session idles or module goes offline
+func (sl *streamListener) relay(route *streamRoute, key string, client net.Addr, s *udpSession) {
+	defer func() {
+		s.untrack()
+		sl.sessions.CompareAndDelete(key, s)
+		s.backend.Close()
+		atomic.AddInt64(&sl.active, -1)
+	}()
+
+	idle := route.rc.IdleTimeout()
+	buf := make([]byte, 64*1024)
+	for {
+		s.backend.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := s.backend.Read(buf)
+		if n > 0 {
+			s.touch()
+			sl.packet.WriteTo(buf[:n], client)
+		}
+		if route.rc.State() != com.Online {
+			return
+		}
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && s.idle() < idle {
+				continue
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Wariie/go-woxy/com"
+)
+
+// streamModule - Route to module bound to addr
+func streamModule(addr net.Addr) *com.RouteConfig {
+	host, port, _ := net.SplitHostPort(addr.String())
+	return &com.RouteConfig{NAME: "stream", STATE: com.Online, BINDING: com.ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}}
+}
+
+// tcpEcho - Module echoing lines prefixed with its name
+func tcpEcho(t *testing.T, name string, cfg *tls.Config) net.Addr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		ln = tls.NewListener(ln, cfg)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					line, err := br.ReadString('\n')
+					if err != nil {
+						return
+					}
+					io.WriteString(conn, name+":"+line)
+				}
+			}()
+		}
+	}()
+	return ln.Addr()
+}
+
+// serveStream - Serve stream listener until test end, returning its address
+func serveStream(t *testing.T, sl *streamListener) string {
+	sl.name = sl.network + "://127.0.0.1:0"
+	if err := sl.listen(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sl.Shutdown(context.Background()) })
+	if sl.packet != nil {
+		return sl.packet.LocalAddr().String()
+	}
+	return sl.listener.Addr().String()
+}
+
+func echoLine(t *testing.T, conn net.Conn, line string) string {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.WriteString(conn, line+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got[:len(got)-1]
+}
+
+func TestStreamSNIRouting(t *testing.T) {
+	_, cert, _ := newTestCore(t)
+	keyPair, err := tls.LoadX509KeyPair(cert.CERT, cert.CERT_KEY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{keyPair}}
+
+	//PASSTHROUGH MODULE TERMINATES TLS ITSELF, TERMINATED ONE GETS PLAIN BYTES
+	passthrough := streamModule(tcpEcho(t, "passthrough", serverTLS))
+	terminated := streamModule(tcpEcho(t, "terminated", nil))
+	fallback := streamModule(tcpEcho(t, "fallback", serverTLS))
+
+	addr := serveStream(t, &streamListener{
+		network: StreamTCP,
+		routes: map[string]*streamRoute{
+			"a.example.com": {rc: passthrough},
+			"b.example.com": {rc: terminated, tls: serverTLS},
+		},
+		fallback: &streamRoute{rc: fallback},
+	})
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{"a.example.com", "passthrough:hello"},
+		{"B.example.com", "terminated:hello"},
+		{"other.example.com", "fallback:hello"},
+		{"", "fallback:hello"},
+	}
+	for _, tt := range tests {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: tt.serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("%q : %v", tt.serverName, err)
+		}
+		if got := echoLine(t, conn, "hello"); got != tt.want {
+			t.Errorf("%q : got %q, want %q", tt.serverName, got, tt.want)
+		}
+		conn.Close()
+	}
+}
+
+func TestPeekServerName(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go tls.Client(client, &tls.Config{ServerName: "mqtt.example.com", InsecureSkipVerify: true}).Handshake()
+
+	name, replay, err := peekServerName(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "mqtt.example.com" {
+		t.Errorf("server name = %q", name)
+	}
+
+	//CLIENT HELLO GIVEN BACK TO NEXT READER
+	b := make([]byte, 1)
+	if _, err := replay.Read(b); err != nil || b[0] != 0x16 {
+		t.Errorf("replayed %x, err %v, want handshake record", b, err)
+	}
+}
+
+// udpEcho - Module echoing datagrams
+func udpEcho(t *testing.T) net.Addr {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr()
+}
+
+func udpRoundTrip(conn net.Conn, msg string) (string, error) {
+	conn.SetDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, err := io.WriteString(conn, msg); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	return string(buf[:n]), err
+}
+
+func TestUDPSessions(t *testing.T) {
+	rc := streamModule(udpEcho(t))
+	sl := &streamListener{network: StreamUDP, routes: map[string]*streamRoute{}, fallback: &streamRoute{rc: rc}, maxSessions: 2}
+	addr := serveStream(t, sl)
+
+	var clients []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		clients = append(clients, conn)
+	}
+
+	for i, conn := range clients[:2] {
+		if got, err := udpRoundTrip(conn, "ping"); err != nil || got != "ping" {
+			t.Fatalf("client %d : got %q, err %v", i, got, err)
+		}
+	}
+
+	//SESSIONS FULL : NEW CLIENT DROPPED
+	if _, err := udpRoundTrip(clients[2], "ping"); err == nil {
+		t.Error("client above max sessions answered")
+	}
+	if n := rc.Upgrades().Active(); n != 2 {
+		t.Errorf("tracked sessions = %d, want 2", n)
+	}
+
+	//MODULE GOING OFFLINE CLOSES SESSIONS, FREEING THEIR SLOTS
+	if n := rc.Upgrades().CloseAll(); n != 2 {
+		t.Errorf("CloseAll() = %d, want 2", n)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for rc.Upgrades().Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := rc.Upgrades().Active(); n != 0 {
+		t.Fatalf("tracked sessions = %d after CloseAll, want 0", n)
+	}
+	if got, err := udpRoundTrip(clients[2], "pong"); err != nil || got != "pong" {
+		t.Errorf("client after sessions freed : got %q, err %v", got, err)
+	}
+}
+
+func TestSupervisedStreamModule(t *testing.T) {
+	interval := streamProbeInterval
+	streamProbeInterval = 0
+	t.Cleanup(func() { streamProbeInterval = interval })
+
+	host, port, _ := net.SplitHostPort(tcpEcho(t, "module", nil).String())
+	core := &Core{config: &Config{}, modulesList: []ModuleConfig{
+		{NAME: "tcp", TYPES: StreamTCP, STATE: com.Loading, BINDING: com.ServerConfig{ADDRESS: host, PORT: port, PROTOCOL: "http"}, EXE: ModuleExecConfig{LastPing: time.Now(), SUPERVISED: true}},
+		{NAME: "udp", TYPES: StreamUDP, STATE: com.Loading, EXE: ModuleExecConfig{LastPing: time.Now(), SUPERVISED: true}, pid: os.Getpid()},
+	}}
+	rc := core.routeConfig(&core.modulesList[0])
+	addr := serveStream(t, &streamListener{network: StreamTCP, fallback: &streamRoute{rc: rc}})
+
+	//LOADING MODULE REFUSED
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection relayed to loading module")
+	}
+	conn.Close()
+
+	s := &Supervisor{core: core}
+	s.Add("tcp")
+	s.Add("udp")
+	s.check()
+	for _, name := range []string{"tcp", "udp"} {
+		if state := core.GetModule(name).STATE; state != com.Online {
+			t.Errorf("%s module state %v, want online", name, state)
+		}
+	}
+	if rc.State() != com.Online {
+		t.Fatalf("route state %v, want online", rc.State())
+	}
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := echoLine(t, conn, "hello"); got != "module:hello" {
+		t.Errorf("got %q", got)
+	}
+
+	//UNREACHABLE MODULE UNKNOWN ONCE PING DELAY IS OVER
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	mod := core.GetModule("tcp")
+	mod.BINDING.ADDRESS, mod.BINDING.PORT, _ = net.SplitHostPort(closed.Addr().String())
+	mod.EXE.LastPing = time.Now().Add(-6 * time.Minute)
+	core.SaveModuleChanges(mod)
+	s.check()
+	if state := core.GetModule("tcp").STATE; state != com.Unknown || rc.State() != com.Unknown {
+		t.Errorf("unreachable module state %v, route %v, want unknown", state, rc.State())
+	}
+}
@@ -12,9 +12,13 @@ import (
 type Supervisor struct {
 	mux        sync.Mutex
 	listModule []string
+	probed     map[string]time.Time
 	core       *Core
 }
 
+// streamProbeInterval - Delay between two health checks of a supervised stream module
+var streamProbeInterval = 5 * time.Second
+
 // Remove -
 func (s *Supervisor) Remove(m string) {
 	s.mux.Lock()
@@ -40,41 +44,70 @@ func (s *Supervisor) Add(m string) {
 func (s *Supervisor) Supervise() {
 	//ENDLESS LOOP
 	for {
-		var mod *ModuleConfig
+		s.check()
+		time.Sleep(time.Millisecond * 100)
+	}
+}
 
-		s.mux.Lock()
-		modulesList := s.listModule
-		s.mux.Unlock()
-		//FOR EACH REGISTERED MODULE
-		for k := range modulesList {
-			//CHECK MODULE RUNNING
+// check - Update state of each registered module once
+func (s *Supervisor) check() {
+	var mod *ModuleConfig
 
-			mod = s.core.GetModule(modulesList[k])
+	s.mux.Lock()
+	modulesList := s.listModule
+	s.mux.Unlock()
+	//FOR EACH REGISTERED MODULE
+	for k := range modulesList {
+		//CHECK MODULE RUNNING
 
-			timeBeforeLastPing := time.Until(mod.EXE.LastPing)
+		mod = s.core.GetModule(modulesList[k])
 
-			var editStat bool = false
+		var editStat bool = false
 
-			//if Loading | Unknown | Online
-			var managingState bool = mod.STATE < com.Downloaded && mod.STATE >= com.Unknown
+		//if Loading | Unknown | Online
+		var managingState bool = mod.STATE < com.Downloaded && mod.STATE >= com.Unknown
 
-			if managingState && timeBeforeLastPing.Minutes() < -5 {
-				if mod.STATE != com.Unknown {
-					mod.STATE = com.Unknown
-					editStat = true
-					log.Println("GO-WOXY Core - Module " + mod.NAME + " not pinging since 5 minutes")
-				}
-			} else if mod.STATE != com.Online && mod.STATE != com.Loading && mod.STATE != com.Downloaded {
+		//STREAM MODULES NEVER CONNECT NOR PING, BEING PROBED INSTEAD
+		if (managingState || mod.STATE == com.Loading) && mod.streamType() != "" && s.probeDue(mod.NAME) && mod.streamHealthy() {
+			if mod.STATE != com.Online {
 				mod.STATE = com.Online
-				editStat = true
+				log.Println("GO-WOXY Core - Stream module " + mod.NAME + " online")
 			}
+			mod.EXE.LastPing = time.Now()
+			editStat = true
+		}
 
-			if editStat {
-				s.core.SaveModuleChanges(mod)
+		timeBeforeLastPing := time.Until(mod.EXE.LastPing)
+
+		if managingState && timeBeforeLastPing.Minutes() < -5 {
+			if mod.STATE != com.Unknown {
+				mod.STATE = com.Unknown
+				editStat = true
+				log.Println("GO-WOXY Core - Module " + mod.NAME + " not pinging since 5 minutes")
 			}
+		} else if mod.STATE != com.Online && mod.STATE != com.Loading && mod.STATE != com.Downloaded {
+			mod.STATE = com.Online
+			editStat = true
 		}
-		time.Sleep(time.Millisecond * 100)
+
+		if editStat {
+			s.core.SaveModuleChanges(mod)
+		}
+	}
+}
+
+// probeDue - Stream module m was not probed since streamProbeInterval
+func (s *Supervisor) probeDue(m string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.probed == nil {
+		s.probed = map[string]time.Time{}
+	}
+	if time.Since(s.probed[m]) < streamProbeInterval {
+		return false
 	}
+	s.probed[m] = time.Now()
+	return true
 }
 
 // SetCore - Set core
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+)
+
+/*TLSConfig - Server TLS policy */
+type TLSConfig struct {
+	ALPN        []string
+	CIPHERS     []string
+	CLIENT_AUTH string
+	CLIENT_CA   string
+	CURVES      []string
+	MAX_VERSION string
+	MIN_VERSION string
+}
+
+var tlsVersions = map[string]uint16{
+	//UNQUOTED YAML 1.0 IS DECODED AS "1"
+	"1":   tls.VersionTLS10,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// apply - Set policy on tls config
+func (tc *TLSConfig) apply(cfg *tls.Config) error {
+	var err error
+	if cfg.MinVersion, err = tlsVersion(tc.MIN_VERSION); err != nil {
+		return err
+	}
+	if cfg.MaxVersion, err = tlsVersion(tc.MAX_VERSION); err != nil {
+		return err
+	}
+	if cfg.MaxVersion != 0 && cfg.MinVersion > cfg.MaxVersion {
+		return errors.New("tls min_version is greater than max_version")
+	}
+
+	for _, name := range tc.CIPHERS {
+		id, err := cipherSuite(name)
+		if err != nil {
+			return err
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+
+	for _, name := range tc.CURVES {
+		curve, ok := tlsCurves[strings.ToUpper(name)]
+		if !ok {
+			return errors.New("unknown tls curve \"" + name + "\"")
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, curve)
+	}
+
+	if len(tc.ALPN) > 0 {
+		cfg.NextProtos = append([]string{}, tc.ALPN...)
+	}
+
+	return tc.applyClientAuth(cfg)
+}
+
+// applyClientAuth - Client certificate verification : off, optional or required
+func (tc *TLSConfig) applyClientAuth(cfg *tls.Config) error {
+	switch tc.CLIENT_AUTH {
+	case "", "off":
+		cfg.ClientAuth = tls.NoClientCert
+		return nil
+	case "optional":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case "required":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return errors.New("unknown tls client_auth \"" + tc.CLIENT_AUTH + "\" (supported : off, optional, required)")
+	}
+
+	if tc.CLIENT_CA == "" {
+		return errors.New("tls client_ca is required to verify client certificates")
+	}
+	pem, err := os.ReadFile(tc.CLIENT_CA)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("no certificate found in " + tc.CLIENT_CA)
+	}
+	cfg.ClientCAs = pool
+	return nil
+}
+
+func tlsVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, errors.New("unknown tls version \"" + version + "\" (supported : 1.0, 1.1, 1.2, 1.3)")
+	}
+	return v, nil
+}
+
+func cipherSuite(name string) (uint16, error) {
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if cs.Name == name {
+			return cs.ID, nil
+		}
+	}
+	return 0, errors.New("unknown tls cipher suite \"" + name + "\"")
+}
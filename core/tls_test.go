@@ -0,0 +1,50 @@
+package core
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestTLSVersionFromYAML(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		min     uint16
+		max     uint16
+		wantErr bool
+	}{
+		{"min_version: 1.0", tls.VersionTLS10, 0, false},
+		{"min_version: '1.0'", tls.VersionTLS10, 0, false},
+		{"min_version: 1", tls.VersionTLS10, 0, false},
+		{"min_version: 1.2\nmax_version: 1.3", tls.VersionTLS12, tls.VersionTLS13, false},
+		{"min_version: 1.3\nmax_version: 1.2", 0, 0, true},
+		{"min_version: 2", 0, 0, true},
+	}
+	for _, tt := range tests {
+		v := viper.New()
+		v.SetConfigType("yml")
+		if err := v.ReadConfig(strings.NewReader(tt.yaml)); err != nil {
+			t.Fatal(err)
+		}
+		var tc TLSConfig
+		if err := v.Unmarshal(&tc); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &tls.Config{}
+		err := tc.apply(cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q accepted", tt.yaml)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q : %v", tt.yaml, err)
+		} else if cfg.MinVersion != tt.min || cfg.MaxVersion != tt.max {
+			t.Errorf("%q : versions %x-%x, want %x-%x", tt.yaml, cfg.MinVersion, cfg.MaxVersion, tt.min, tt.max)
+		}
+	}
+}
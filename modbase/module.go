@@ -8,7 +8,6 @@ import (
 	"encoding/base64"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -47,7 +46,9 @@ type (
 		HubServer      com.Server
 		Server         com.Server
 		ResourcePath   string
+		SocketMode     os.FileMode
 		Certs          []string
+		peer           *com.PeerTLS
 		CustomCommands map[string]func(r *com.Request, w http.ResponseWriter, re *http.Request, mod *ModuleImpl) (string, error)
 	}
 )
@@ -149,6 +150,7 @@ func (mod *ModuleImpl) Init() {
 	GetModManager().SetMod(mod)
 
 	mod.readSecret()
+	mod.readPeerTLS()
 
 	if mod.ResourcePath == "" {
 		mod.ResourcePath = "/resources"
@@ -157,21 +159,41 @@ func (mod *ModuleImpl) Init() {
 	//DEFAULT MODULE SERVER PARAMETER
 	if mod.Server == (com.Server{}) {
 		mod.Server = com.Server{IP: "0.0.0.0", Port: "4224", Protocol: "http"}
-	} else if len(mod.Certs) == 2 {
+	} else if len(mod.Certs) == 2 && mod.Server.Protocol != com.Unix {
 		if mod.Server.Port == "" {
 			mod.Server.Port = "443"
 		}
 		mod.Server.Protocol = "https"
 	}
 
+	//MUTUAL TLS WITH HUB
+	if mod.peer != nil && mod.Server.Protocol != com.Unix {
+		mod.Server.Protocol = "https"
+	}
+
 	//DEFAULT HUB SERVER PARAMETERS
 	if mod.HubServer == (com.Server{}) {
-		mod.HubServer = com.Server{IP: "0.0.0.0", Port: "2000", Protocol: "http"}
+		mod.HubServer = hubServerFromEnv()
 	} else if mod.HubServer.Protocol == "https" && mod.HubServer.Port == "" {
 		mod.HubServer.Port = "443"
 	}
 }
 
+// hubServerFromEnv - Hub server given by go-woxy when it started the module
+func hubServerFromEnv() com.Server {
+	s := com.Server{IP: "0.0.0.0", Port: "2000", Protocol: "http"}
+	if ip := os.Getenv(com.EnvHubAddress); ip != "" {
+		s.IP = com.IP(ip)
+	}
+	if port := os.Getenv(com.EnvHubPort); port != "" {
+		s.Port = com.Port(port)
+	}
+	if proto := os.Getenv(com.EnvHubProtocol); proto != "" {
+		s.Protocol = com.Protocol(proto)
+	}
+	return s
+}
+
 func (mod *ModuleImpl) readSecret() {
 	b, err := ioutil.ReadFile(".secret")
 	if err != nil {
@@ -183,6 +205,20 @@ func (mod *ModuleImpl) readSecret() {
 	mod.Secret = base64.URLEncoding.EncodeToString(h.Sum(nil))
 }
 
+// readPeerTLS - Certificate issued by hub internal CA, mutual TLS being disabled without it
+func (mod *ModuleImpl) readPeerTLS() {
+	if _, err := os.Stat(com.PeerCAFile); os.IsNotExist(err) {
+		return
+	}
+	peer, err := com.LoadPeerTLS(".")
+	if err != nil {
+		log.Println("Error reading hub certificates :", err)
+		os.Exit(2)
+	}
+	mod.peer = peer
+	com.SetPeerTLS(peer)
+}
+
 type HttpServer struct {
 	http.Server
 	shutdownReq chan bool
@@ -216,9 +252,14 @@ func (mod *ModuleImpl) serve() {
 	s := GetModManager().GetMod().Server
 	r.Handle("/cmd", cmd(), nil, &com.Route{TO: "/cmd"})
 
+	addr := string(s.IP) + ":" + string(s.Port)
+	if s.Protocol == com.Unix {
+		addr = string(s.IP)
+	}
+
 	server := &HttpServer{
 		Server: http.Server{
-			Addr:         string(s.IP) + ":" + string(s.Port),
+			Addr:         addr,
 			Handler:      r,
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
@@ -231,9 +272,14 @@ func (mod *ModuleImpl) serve() {
 		go checkHubRunning(mod.HubServer, mod)
 	}
 
-	var listener net.Listener
+	listener, err := com.Listen(string(s.Protocol), string(s.IP), string(s.Port), mod.SocketMode)
+	if err != nil {
+		log.Fatalln("Error setupping listener :", err)
+	}
 
-	if len(mod.Certs) == 2 { //CERTIFCATE AND KEY DETECTED
+	if mod.peer != nil && s.Protocol != com.Unix { //ONLY ACCEPT HUB AND MODULES FROM INTERNAL CA
+		listener = tls.NewListener(listener, mod.peer.ServerConfig())
+	} else if len(mod.Certs) == 2 { //CERTIFCATE AND KEY DETECTED
 		var cfg tls.Config
 		cer, err := tls.LoadX509KeyPair(mod.Certs[0], mod.Certs[1])
 		if err != nil {
@@ -242,29 +288,16 @@ func (mod *ModuleImpl) serve() {
 		} else {
 			cfg = tls.Config{Certificates: []tls.Certificate{cer}}
 		}
-
-		//server.TLSConfig = &cfg
-
-		listener, err = tls.Listen("tcp", string(s.IP)+":"+string(s.Port), &cfg)
-		if err != nil {
-			log.Fatalln("Error setuping https listener :", err)
-		}
-	} else {
-
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalln("Error setupping http listener :", err)
-		}
+		listener = tls.NewListener(listener, &cfg)
 	}
 
-	server.Serve(listener)
-
 	GetModManager().SetServer(server)
 
 	done := make(chan bool)
 	go func() {
-		err := server.ListenAndServe()
+		err := server.Serve(listener)
 		if err != nil {
-			log.Printf("Listen and serve: %v", err)
+			log.Printf("Serve: %v", err)
 		}
 		done <- true
 	}()